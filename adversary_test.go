@@ -0,0 +1,117 @@
+package kth
+
+import (
+	"cmp"
+	"fmt"
+	"math/bits"
+	"slices"
+	"testing"
+)
+
+// adversary implements McIlroy's "A Killer Adversary for Quicksort". Elements are
+// identified by their original index and all start out as gas, which compares greater
+// than anything else. They're frozen to increasing values as needed to answer
+// comparisons, preferring to freeze anything but the current pivot candidate. Since
+// answers stay consistent with the values elements end up with, those values make up
+// an input crafted against the exact sequence of comparisons made by the algorithm.
+type adversary struct {
+	values    []int // values of the elements, by id
+	ncmp      int   // number of comparisons
+	nsolid    int   // number of frozen elements
+	candidate int   // guess at the current pivot
+	gas       int   // value of elements that haven't been frozen yet
+}
+
+func newAdversary(n int) *adversary {
+	d := &adversary{values: make([]int, n), gas: n}
+	for i := range d.values {
+		d.values[i] = d.gas
+	}
+	return d
+}
+
+func (d *adversary) less(a, b int) bool {
+	d.ncmp++
+	if d.values[a] == d.gas && d.values[b] == d.gas {
+		if a == d.candidate {
+			d.values[a] = d.nsolid
+		} else {
+			d.values[b] = d.nsolid
+		}
+		d.nsolid++
+	}
+
+	if d.values[a] == d.gas {
+		d.candidate = a
+	} else if d.values[b] == d.gas {
+		d.candidate = b
+	}
+
+	return d.values[a] < d.values[b]
+}
+
+func (d *adversary) compare(a, b int) int {
+	d.less(a, b) // Freezes at least one of a and b.
+	return cmp.Compare(d.values[a], d.values[b])
+}
+
+// adversaryIDs adapts an adversary to sort.Interface over a slice of element ids.
+type adversaryIDs struct {
+	*adversary
+	ids []int
+}
+
+func (d adversaryIDs) Len() int           { return len(d.ids) }
+func (d adversaryIDs) Less(i, j int) bool { return d.less(d.ids[i], d.ids[j]) }
+func (d adversaryIDs) Swap(i, j int)      { d.ids[i], d.ids[j] = d.ids[j], d.ids[i] }
+
+func TestAdversary(t *testing.T) {
+	funcs := []struct {
+		name string
+		fn   func(d *adversary, ids []int, k int)
+	}{
+		{"PDQSelect", func(d *adversary, ids []int, k int) { PDQSelect(adversaryIDs{d, ids}, k) }},
+		{"PDQSelectFunc", func(d *adversary, ids []int, k int) { PDQSelectFunc(ids, k, d.less) }},
+		{"FloydRivest", func(d *adversary, ids []int, k int) { FloydRivest(adversaryIDs{d, ids}, k) }},
+		{"FloydRivestFunc", func(d *adversary, ids []int, k int) { FloydRivestFunc(ids, k, d.less) }},
+		{"FloydRivestCmpFunc", func(d *adversary, ids []int, k int) { FloydRivestCmpFunc(ids, k, d.compare) }},
+	}
+
+	for _, n := range []int{1000, 16_000, 100_000} {
+		for _, k := range []int{1, n / 4, n / 2, n} {
+			for _, f := range funcs {
+				t.Run(fmt.Sprintf("%s/n=%d/k=%d", f.name, n, k), func(t *testing.T) {
+					d := newAdversary(n)
+					ids := identity(n)
+					f.fn(d, ids, k)
+
+					// Bad partitioning steps are tolerated at most log2(n) times before
+					// falling back to heapSelect, and each of them costs O(n) comparisons.
+					if bound := 2 * n * bits.Len(uint(n)); d.ncmp > bound {
+						t.Errorf("made %d comparisons, want at most %d", d.ncmp, bound)
+					}
+
+					sorted := slices.Clone(d.values)
+					slices.Sort(sorted)
+					if got := d.values[ids[k-1]]; got != sorted[k-1] {
+						t.Errorf("k-th element = %d, want %d", got, sorted[k-1])
+					}
+
+					// The Ordered variants make the same comparisons as the Func ones,
+					// so the crafted input applies to them as well.
+					if f.name == "PDQSelectFunc" || f.name == "FloydRivestFunc" {
+						input := slices.Clone(d.values)
+						if f.name == "PDQSelectFunc" {
+							PDQSelectOrdered(input, k)
+						} else {
+							FloydRivestOrdered(input, k)
+						}
+						if input[k-1] != sorted[k-1] {
+							t.Errorf("Ordered variant: k-th element = %d, want %d", input[k-1], sorted[k-1])
+						}
+					}
+				})
+			}
+		}
+	}
+}
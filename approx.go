@@ -0,0 +1,196 @@
+package kth
+
+import (
+	"cmp"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"slices"
+)
+
+const (
+	// defaultApproxSampleSize is the sample size ApproxSelect uses by default, for which
+	// the rank error is within 1.3% of len(data) with 99% confidence.
+	defaultApproxSampleSize = 1 << 14
+
+	// defaultApproxConfidence is the confidence ApproxSelect uses by default.
+	defaultApproxConfidence = 0.99
+)
+
+// ApproxOptions configures ApproxSelect and ApproxQuantile. The zero value samples
+// 16384 elements and reports ranks that hold with 99% confidence.
+type ApproxOptions struct {
+	// SampleSize is the number of elements sampled. The rank error shrinks with its
+	// square root. If it's not positive, 16384 elements are sampled.
+	SampleSize int
+
+	// Confidence is the probability with which the rank interval of the result holds,
+	// within (0, 1). If it's zero, 0.99 is used.
+	Confidence float64
+
+	// Refine makes ApproxSelect find the exact answer, by partitioning data around two
+	// elements of the sample that bracket it with the given confidence and selecting
+	// among the elements between them. This reorders data as PDQSelectOrdered does, and
+	// is exact even when the bracket misses, which only costs another selection.
+	Refine bool
+
+	// Rand is the source of the sample. If it's nil, the global source of math/rand/v2
+	// is used. The confidence is only warranted if data doesn't depend on it.
+	Rand *rand.Rand
+}
+
+// ApproxResult is the answer of ApproxSelect and ApproxQuantile.
+type ApproxResult[T any] struct {
+	// Value is the estimate of the element of the requested rank.
+	Value T
+
+	// Lo and Hi bound the rank of Value: with the requested confidence, Value would be
+	// found at one of the 1-based positions within [Lo, Hi] if data was sorted. Elements
+	// equal to Value occupy consecutive positions, of which at least one is within.
+	Lo, Hi int
+
+	// Exact reports whether Value is the exact answer, in which case Lo and Hi are the
+	// requested rank.
+	Exact bool
+}
+
+// ApproxSelect estimates the k-th smallest element of data from a random sample, which
+// costs O(s) time for a sample of s elements, however large data is, and returns it
+// along with a rank interval that holds with the requested confidence. Unless opts.Refine
+// is set, data is only read.
+//
+// The sample is drawn uniformly with replacement, and its element of rank ⌈ks/n⌉ is
+// selected with PDQSelectOrdered. By the Dvoretzky–Kiefer–Wolfowitz inequality, the
+// fraction of the sample below any value is then within ε = √(ln(2/δ)/2s) of the
+// fraction of data below it, except with probability δ = 1 - confidence, which bounds
+// the rank of the estimate within k ± εn, give or take n/s.
+// The same bound picks the two elements of the sample that opts.Refine partitions
+// around, in the manner of Floyd and Rivest.
+//
+// When the sample would be at least as large as data, the answer is exact and found
+// with PDQSelectOrdered, on a copy of data unless opts.Refine is set.
+//
+// ApproxSelect returns false if k is not within [1, len(data)], and panics if
+// opts.Confidence is not within [0, 1).
+func ApproxSelect[T cmp.Ordered](data []T, k int, opts ApproxOptions) (ApproxResult[T], bool) {
+	n := len(data)
+	if k < 1 || k > n {
+		return ApproxResult[T]{}, false
+	}
+
+	s := opts.SampleSize
+	if s <= 0 {
+		s = defaultApproxSampleSize
+	}
+	confidence := opts.Confidence
+	if confidence == 0 {
+		confidence = defaultApproxConfidence
+	}
+	if !(confidence > 0 && confidence < 1) {
+		panic("kth: confidence out of range")
+	}
+
+	if s >= n {
+		if !opts.Refine {
+			data = append([]T(nil), data...)
+		}
+		pdqselectOrdered(data, 0, n, k-1, bits.Len(uint(n)))
+		return ApproxResult[T]{Value: data[k-1], Lo: k, Hi: k, Exact: true}, true
+	}
+
+	intN := rand.IntN
+	if opts.Rand != nil {
+		intN = opts.Rand.IntN
+	}
+	sample := make([]T, s)
+	for i := range sample {
+		sample[i] = data[intN(n)]
+	}
+
+	// eps is the largest difference between the fractions of the sample and of data
+	// below any value, except with probability 1 - confidence.
+	eps := math.Sqrt(math.Log(2/(1-confidence)) / float64(2*s))
+	nf, sf, kf := float64(n), float64(s), float64(k)
+
+	// The estimate is the sample's element of rank j. With at most j-1 elements of the
+	// sample below it and at least j up to it, data has at most n((j-1)/s + eps) elements
+	// below it and at least n(j/s - eps) up to it, so one of its ranks lies in between.
+	j := min(max(int(math.Ceil(kf*sf/nf)), 1), s)
+
+	// The k-th smallest element has at most k-1 elements of data below it, so at most
+	// s((k-1)/n + eps) elements of the sample, and the sample's element of rank one
+	// more is no smaller. Likewise, the sample's element of rank s(k/n - eps) is no
+	// greater. Ranks outside of [1, s] leave the bracket open on that side.
+	lo := int(math.Ceil(sf * (kf/nf - eps)))
+	hi := int(math.Floor(sf*((kf-1)/nf+eps))) + 1
+
+	ranks := []int{j - 1}
+	if lo >= 1 {
+		ranks = append(ranks, lo-1)
+	}
+	if hi <= s {
+		ranks = append(ranks, hi-1)
+	}
+	slices.Sort(ranks)
+	ranks = slices.Compact(ranks)
+	pdqselectMultiOrdered(sample, 0, s, ranks, bits.Len(uint(s)))
+
+	if !opts.Refine {
+		return ApproxResult[T]{
+			Value: sample[j-1],
+			Lo:    max(int(math.Ceil(nf*(float64(j)/sf-eps))), 1),
+			Hi:    min(int(math.Floor(nf*(float64(j-1)/sf+eps)))+1, n),
+		}, true
+	}
+
+	// Split data into the elements below the bracket, within it, and above it, and
+	// select within the part that holds rank k, which is the bracket unless it missed.
+	a, b := 0, n
+	if lo >= 1 {
+		m := approxSplitOrdered(data, sample[lo-1])
+		if k-1 < m {
+			b = m // The bracket missed: rank k is below it.
+		} else {
+			a = m
+		}
+	}
+	if hi <= s && b == n {
+		q := sample[hi-1]
+		m := a + approxSplitOrdered(data[a:], q)
+		if k-1 >= m {
+			// Elements equal to q are within the bracket too, but are rarely many.
+			m += splitLessOrEqualOrdered(data[m:], q)
+		}
+		if k-1 < m {
+			b = m
+		} else {
+			a = m // The bracket missed: rank k is above it.
+		}
+	}
+	pdqselectOrdered(data, a, b, k-1, bits.Len(uint(b-a)))
+	return ApproxResult[T]{Value: data[k-1], Lo: k, Hi: k, Exact: true}, true
+}
+
+// ApproxQuantile estimates the q-th quantile of data, for q in [0, 1], as ApproxSelect
+// does, taking the quantile to be the element of rank ⌈qn⌉ (at least 1), as with
+// InvertedCDF. It returns false if data is empty or q is out of range.
+func ApproxQuantile[T cmp.Ordered](data []T, q float64, opts ApproxOptions) (ApproxResult[T], bool) {
+	n := len(data)
+	if n == 0 || !(q >= 0 && q <= 1) {
+		return ApproxResult[T]{}, false
+	}
+	lo, hi, h := InvertedCDF.index(n, q)
+	if h == 1 {
+		lo = hi
+	}
+	return ApproxSelect(data, lo+1, opts)
+}
+
+// approxSplitOrdered moves the elements of data smaller than p to its front and returns
+// how many there are, partitioning in blocks when pdqselect would.
+func approxSplitOrdered[T cmp.Ordered](data []T, p T) int {
+	if useBlockPartition[T](len(data)) {
+		return partitionLessOrdered(data, p)
+	}
+	return splitLessOrdered(data, p)
+}
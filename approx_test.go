@@ -0,0 +1,113 @@
+package kth
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestApproxSelect(t *testing.T) {
+	rng := rand.New(rand.NewPCG(45, 46))
+
+	t.Run("Coverage", func(t *testing.T) {
+		const (
+			size       = 100000
+			trials     = 100
+			confidence = 0.9
+		)
+		for _, dist := range []Distribution{UniformDist, ZipfDist, BimodalDist} {
+			input := genDistribution(rng, size, dist)
+			sorted := slices.Clone(input)
+			slices.Sort(sorted)
+
+			misses := 0
+			for trial := range trials {
+				k := 1 + trial*(size-1)/(trials-1)
+				res, ok := ApproxSelect(input, k, ApproxOptions{SampleSize: 1000, Confidence: confidence, Rand: rng})
+				if !ok || res.Exact || res.Lo > res.Hi {
+					t.Fatalf("dist=%s/k=%d: ApproxSelect = %+v, %t", dist, k, res, ok)
+				}
+				// The ranks of res.Value in sorted data span [first+1, last].
+				first, _ := slices.BinarySearch(sorted, res.Value)
+				last, _ := slices.BinarySearch(sorted, res.Value+1)
+				if res.Hi <= first || res.Lo > last {
+					misses++
+				}
+			}
+			if misses > trials*(1-confidence) {
+				t.Errorf("dist=%s: %d out of %d rank intervals missed", dist, misses, trials)
+			}
+		}
+	})
+
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist, BimodalDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder} {
+			for _, size := range []int{1, 100, 5000} {
+				input := genDistribution(rng, size, dist)
+				applyOrdering(rng, input, order)
+
+				for _, k := range []int{1, size / 3, size} {
+					k = max(k, 1)
+					name := fmt.Sprintf("Refine/n=%d/k=%d/dist=%s/order=%s", size, k, dist, order)
+					t.Run(name, func(t *testing.T) {
+						// Small samples and low confidence make the bracket miss now and then.
+						for _, opts := range []ApproxOptions{
+							{Refine: true},
+							{Refine: true, SampleSize: 50, Confidence: 0.01, Rand: rng},
+							{Refine: true, SampleSize: 500, Rand: rng},
+						} {
+							testSelect(t, input, 0, size, k, "ApproxSelect", func(data []int, _, _, k int) {
+								res, ok := ApproxSelect(data, k, opts)
+								if !ok || !res.Exact || res.Lo != k || res.Hi != k || res.Value != data[k-1] {
+									t.Fatalf("ApproxSelect = %+v, %t", res, ok)
+								}
+							})
+						}
+					})
+				}
+			}
+		}
+	}
+
+	t.Run("Read only", func(t *testing.T) {
+		input := genDistribution(rng, 5000, UniformDist)
+		data := slices.Clone(input)
+		for _, s := range []int{100, 10000} {
+			ApproxSelect(data, 2500, ApproxOptions{SampleSize: s})
+			if !slices.Equal(data, input) {
+				t.Fatalf("SampleSize=%d: data was modified", s)
+			}
+		}
+	})
+
+	t.Run("Quantile", func(t *testing.T) {
+		data := []int{5, 1, 4, 2, 3}
+		for _, tc := range []struct {
+			q    float64
+			want int
+		}{{0, 1}, {0.2, 1}, {0.21, 2}, {0.5, 3}, {1, 5}} {
+			res, ok := ApproxQuantile(slices.Clone(data), tc.q, ApproxOptions{})
+			if !ok || res.Value != tc.want || !res.Exact {
+				t.Errorf("ApproxQuantile(q=%v) = %+v, %t, want %d", tc.q, res, ok, tc.want)
+			}
+		}
+		if _, ok := ApproxQuantile(data, 1.5, ApproxOptions{}); ok {
+			t.Error("ApproxQuantile(q=1.5) succeeded")
+		}
+	})
+
+	t.Run("Out of range", func(t *testing.T) {
+		for _, k := range []int{0, 4} {
+			if _, ok := ApproxSelect([]int{3, 1, 2}, k, ApproxOptions{}); ok {
+				t.Fatalf("k=%d: ApproxSelect succeeded", k)
+			}
+		}
+		defer func() {
+			if recover() == nil {
+				t.Fatal("ApproxSelect didn't panic")
+			}
+		}()
+		ApproxSelect([]int{3, 1, 2}, 1, ApproxOptions{Confidence: 1})
+	})
+}
@@ -0,0 +1,143 @@
+package kth
+
+import (
+	"cmp"
+	"math/bits"
+)
+
+// Lesser is the read-only subset of sort.Interface needed to compare elements by index.
+// It's what the ArgSelect functions use so that data can be shared with other goroutines
+// or otherwise not be written to.
+type Lesser interface {
+	// Len is the number of elements in the collection.
+	Len() int
+	// Less reports whether the element with index i must sort before the element with index j.
+	Less(i, j int) bool
+}
+
+// ArgSelect is the non-mutating counterpart of PDQSelect. Rather than swapping elements of
+// data, it returns a permutation of the indices [0, n) such that perm[:k] are the indices
+// of the smallest k elements and perm[k-1] is the index of the k-th smallest element.
+//
+// Like PDQSelect, it leaves the permutation as the identity when k is not within [1, n].
+// Use ApplyPermutation to reorder data, or any slices parallel to it, accordingly.
+func ArgSelect(data Lesser, k int) []int {
+	n := data.Len()
+	perm := identity(n)
+	if k < 1 || k > n {
+		return perm
+	}
+	pdqselect(&argSorter{data, perm}, 0, n, k-1, bits.Len(uint(n)))
+	return perm
+}
+
+// ArgSelectOrdered is a specialized version of ArgSelect that works with slices of
+// ordered types (i.e. types that implement the cmp.Ordered interface).
+func ArgSelectOrdered[T cmp.Ordered](data []T, k int) []int {
+	return ArgSelectFunc(data, k, cmp.Less[T])
+}
+
+// ArgSelectFunc is a generic version of ArgSelect that allows the caller to provide
+// a custom comparison function to determine the order of elements.
+func ArgSelectFunc[E any](data []E, k int, less func(a, b E) bool) []int {
+	n := len(data)
+	perm := identity(n)
+	if k < 1 || k > n {
+		return perm
+	}
+	pdqselectFunc(perm, 0, n, k-1, bits.Len(uint(n)), func(i, j int) bool {
+		return less(data[i], data[j])
+	})
+	return perm
+}
+
+// ArgPartialSort is the non-mutating counterpart of PartialSort. It returns a permutation
+// of the indices [0, n) such that perm[:k] are the indices of the smallest k elements,
+// ordered from smallest to largest.
+func ArgPartialSort(data Lesser, k int) []int {
+	n := data.Len()
+	perm := identity(n)
+	if k < 1 || k > n {
+		return perm
+	}
+	partialSort(&argSorter{data, perm}, 0, n, k, bits.Len(uint(n)))
+	return perm
+}
+
+// ArgPartialSortOrdered is a specialized version of ArgPartialSort that works with slices of
+// ordered types (i.e. types that implement the cmp.Ordered interface).
+func ArgPartialSortOrdered[T cmp.Ordered](data []T, k int) []int {
+	return ArgPartialSortFunc(data, k, cmp.Less[T])
+}
+
+// ArgPartialSortFunc is a generic version of ArgPartialSort that allows the caller to provide
+// a custom comparison function to determine the order of elements.
+func ArgPartialSortFunc[E any](data []E, k int, less func(a, b E) bool) []int {
+	n := len(data)
+	perm := identity(n)
+	if k < 1 || k > n {
+		return perm
+	}
+	partialSortFunc(perm, 0, n, k, bits.Len(uint(n)), func(i, j int) bool {
+		return less(data[i], data[j])
+	})
+	return perm
+}
+
+// ApplyPermutation reorders one or more collections in place so that the element at
+// index i afterwards is the one that was at index perm[i] before, as returned by the
+// ArgSelect functions. Each collection is represented by a function swapping two of its
+// elements, such as the one returned by reflect.Swapper.
+//
+// It follows the cycles of perm, so each collection sees at most n-1 swaps and no
+// copy of it is made. perm itself is not modified. ApplyPermutation panics if perm
+// is not a permutation of [0, len(perm)).
+func ApplyPermutation(perm []int, swaps ...func(i, j int)) {
+	n := len(perm)
+	pending := make([]uint64, (n+63)/64)
+
+	// Check perm before the first swap, so that the collections are left untouched if
+	// it's invalid. Once it's checked, every index is pending until its cycle is applied.
+	for _, j := range perm {
+		if j < 0 || j >= n || pending[j/64]&(1<<(j%64)) != 0 {
+			panic("kth: ApplyPermutation: perm is not a permutation")
+		}
+		pending[j/64] |= 1 << (j % 64)
+	}
+
+	for i := range perm {
+		if pending[i/64]&(1<<(i%64)) == 0 {
+			continue
+		}
+		pending[i/64] &^= 1 << (i % 64)
+
+		for j := i; perm[j] != i; {
+			next := perm[j]
+			pending[next/64] &^= 1 << (next % 64)
+
+			for _, swap := range swaps {
+				swap(j, next)
+			}
+			j = next
+		}
+	}
+}
+
+// identity returns the identity permutation of [0, n).
+func identity(n int) []int {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	return perm
+}
+
+// argSorter is a sort.Interface that swaps indices into data rather than data itself.
+type argSorter struct {
+	data Lesser
+	perm []int
+}
+
+func (s *argSorter) Len() int           { return len(s.perm) }
+func (s *argSorter) Less(i, j int) bool { return s.data.Less(s.perm[i], s.perm[j]) }
+func (s *argSorter) Swap(i, j int)      { s.perm[i], s.perm[j] = s.perm[j], s.perm[i] }
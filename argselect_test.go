@@ -0,0 +1,162 @@
+package kth
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestArgSelect(t *testing.T) {
+	rng := rand.New(rand.NewPCG(9, 10))
+
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder} {
+			for _, size := range []int{1, 13, 1000} {
+				input := genDistribution(rng, size, dist)
+				applyOrdering(rng, input, order)
+				sorted := slices.Clone(input)
+				slices.Sort(sorted)
+
+				for _, k := range []int{1, size / 2, size} {
+					k = max(k, 1)
+					name := fmt.Sprintf("n=%d/k=%d/dist=%s/order=%s", size, k, dist, order)
+					data := slices.Clone(input)
+
+					t.Run("ArgSelect/"+name, func(t *testing.T) {
+						for _, perm := range [][]int{
+							ArgSelect(sort.IntSlice(data), k),
+							ArgSelectOrdered(data, k),
+							ArgSelectFunc(data, k, cmp.Less),
+						} {
+							checkPermutation(t, perm, size)
+							if got := data[perm[k-1]]; got != sorted[k-1] {
+								t.Errorf("k-th element = %d, want %d", got, sorted[k-1])
+							}
+							for _, i := range perm[:k] {
+								if data[i] > sorted[k-1] {
+									t.Errorf("element %d at index %d is larger than the k-th element %d", data[i], i, sorted[k-1])
+								}
+							}
+						}
+						if !slices.Equal(data, input) {
+							t.Fatalf("data was modified")
+						}
+					})
+
+					t.Run("ArgPartialSort/"+name, func(t *testing.T) {
+						for _, perm := range [][]int{
+							ArgPartialSort(sort.IntSlice(data), k),
+							ArgPartialSortOrdered(data, k),
+							ArgPartialSortFunc(data, k, cmp.Less),
+						} {
+							checkPermutation(t, perm, size)
+							for i, j := range perm[:k] {
+								if data[j] != sorted[i] {
+									t.Fatalf("element of rank %d = %d, want %d", i+1, data[j], sorted[i])
+								}
+							}
+						}
+						if !slices.Equal(data, input) {
+							t.Fatalf("data was modified")
+						}
+					})
+				}
+			}
+		}
+	}
+
+	t.Run("Out of range", func(t *testing.T) {
+		for _, k := range []int{0, 4} {
+			if perm := ArgSelectOrdered([]int{3, 1, 2}, k); !slices.Equal(perm, []int{0, 1, 2}) {
+				t.Errorf("k=%d: got %v, want identity", k, perm)
+			}
+		}
+		if perm := ArgSelectOrdered([]int{}, 1); len(perm) != 0 {
+			t.Errorf("empty input: got %v", perm)
+		}
+	})
+}
+
+func TestApplyPermutation(t *testing.T) {
+	rng := rand.New(rand.NewPCG(11, 12))
+
+	for _, size := range []int{0, 1, 2, 10, 100, 1000} {
+		t.Run(fmt.Sprintf("n=%d", size), func(t *testing.T) {
+			keys := make([]float64, size)
+			names := make([]string, size)
+			for i := range keys {
+				keys[i] = rng.Float64()
+				names[i] = fmt.Sprint(keys[i])
+			}
+
+			perm := rng.Perm(size)
+			permCopy := slices.Clone(perm)
+
+			wantKeys := make([]float64, size)
+			for i, j := range perm {
+				wantKeys[i] = keys[j]
+			}
+
+			ApplyPermutation(perm,
+				func(i, j int) { keys[i], keys[j] = keys[j], keys[i] },
+				func(i, j int) { names[i], names[j] = names[j], names[i] },
+			)
+
+			if !slices.Equal(keys, wantKeys) {
+				t.Errorf("keys not permuted correctly")
+			}
+			for i := range keys {
+				if names[i] != fmt.Sprint(keys[i]) {
+					t.Fatalf("parallel slices out of sync at %d", i)
+				}
+			}
+			if !slices.Equal(perm, permCopy) {
+				t.Errorf("perm was modified")
+			}
+		})
+	}
+
+	t.Run("ArgPartialSort", func(t *testing.T) {
+		scores := []int{50, 10, 40, 20, 30}
+		ids := []string{"e", "a", "d", "b", "c"}
+		perm := ArgPartialSortOrdered(scores, 3)
+		ApplyPermutation(perm,
+			func(i, j int) { scores[i], scores[j] = scores[j], scores[i] },
+			func(i, j int) { ids[i], ids[j] = ids[j], ids[i] },
+		)
+		if !slices.Equal(scores[:3], []int{10, 20, 30}) || !slices.Equal(ids[:3], []string{"a", "b", "c"}) {
+			t.Errorf("got scores %v and ids %v", scores, ids)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		// The first cycles of the last ones are valid, but nothing must be swapped before
+		// the invalid part is found.
+		for _, perm := range [][]int{{1, 1}, {0, 2}, {-1, 0}, {1, 0, 3, 3}, {2, 0, 1, 5, 3}} {
+			swaps := 0
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("ApplyPermutation(%v) did not panic", perm)
+					}
+				}()
+				ApplyPermutation(perm, func(i, j int) { swaps++ })
+			}()
+			if swaps != 0 {
+				t.Errorf("ApplyPermutation(%v) swapped %d times before panicking", perm, swaps)
+			}
+		}
+	})
+}
+
+func checkPermutation(t *testing.T, perm []int, n int) {
+	t.Helper()
+	sorted := slices.Clone(perm)
+	slices.Sort(sorted)
+	if !slices.Equal(sorted, identity(n)) {
+		t.Fatalf("result is not a permutation of [0, %d): %v", n, perm)
+	}
+}
@@ -0,0 +1,185 @@
+package kth
+
+import (
+	"cmp"
+	"unsafe"
+)
+
+const (
+	// blockSize is the number of elements whose offsets blockPartitionOrdered buffers
+	// on each side before exchanging them, small enough for the offsets to fit in uint8s.
+	blockSize = 128
+
+	// blockPartitionThreshold is the length below which partitioning in blocks doesn't
+	// pay off, as measured on random data: the cost of filling and draining the offset
+	// buffers only amortizes over longer inputs.
+	blockPartitionThreshold = 16 * blockSize
+
+	// blockMaxSize is the largest element size, in bytes, for which the Ordered variants
+	// partition in blocks. Comparisons of larger types, which are strings, cost more
+	// than the branch mispredictions partitioning in blocks avoids.
+	blockMaxSize = 8
+)
+
+// useBlockPartition reports whether the Ordered variants partition n elements of type E
+// in blocks.
+func useBlockPartition[E cmp.Ordered](n int) bool {
+	var zero E
+	return n >= blockPartitionThreshold && unsafe.Sizeof(zero) <= blockMaxSize
+}
+
+// partitionBlockOrdered is a drop-in replacement for partitionOrdered that partitions
+// in blocks once it finds a misplaced pair of elements.
+func partitionBlockOrdered[E cmp.Ordered](data []E, a, b, pivot int) (newpivot int, alreadyPartitioned bool) {
+	data[a], data[pivot] = data[pivot], data[a]
+	i, j := a+1, b-1 // i and j are inclusive of the elements remaining to be partitioned
+
+	for i <= j && cmp.Less(data[i], data[a]) {
+		i++
+	}
+	for i <= j && !cmp.Less(data[j], data[a]) {
+		j--
+	}
+	if i > j {
+		data[j], data[a] = data[a], data[j]
+		return j, true
+	}
+
+	// data[j] is smaller than the pivot, so at least one element ends up left of it.
+	mid := i + partitionLessOrdered(data[i:j+1], data[a]) - 1
+	data[mid], data[a] = data[a], data[mid]
+	return mid, false
+}
+
+// partitionLessOrdered moves the elements of data smaller than p according to cmp.Less
+// before the others, and returns how many there are. It uses the vectorized kernels of
+// partitionSIMD when the CPU has them for E, and partitions in blocks otherwise.
+func partitionLessOrdered[E cmp.Ordered](data []E, p E) int {
+	if m, ok := partitionSIMD(data, p); ok {
+		return m
+	}
+	return blockPartitionOrdered(data, p, true)
+}
+
+// blockPartitionOrdered moves the elements of data that belong left of p before those
+// that belong right of it, and returns how many belong left. When strict is set, those
+// are the elements smaller than p according to cmp.Less, as in partitionOrdered.
+// Otherwise, they are the elements not greater than p, and elements equal to p may
+// end up on either side, as in Hoare's scheme, which keeps duplicates balanced.
+//
+// It follows Edelkamp and Weiß's BlockQuicksort: rather than swapping each misplaced
+// element as soon as it's found, which makes the comparisons feed unpredictable
+// branches, it scans a block from each end, storing the offsets of misplaced elements
+// unconditionally and advancing the count of them by the outcome of the comparison,
+// and then exchanges as many of them as both blocks hold.
+func blockPartitionOrdered[E cmp.Ordered](data []E, p E, strict bool) int {
+	var offsetsL, offsetsR [blockSize]uint8
+	var startL, numL, startR, numR int
+	l, r := 0, len(data) // data[l:r] remains to be partitioned
+
+	for r-l >= 2*blockSize {
+		// The flavour is chosen outside of the scans, which would otherwise branch on it
+		// for every element.
+		if numL == 0 {
+			startL = 0
+			block := data[l : l+blockSize]
+			if strict {
+				for i, x := range block {
+					offsetsL[numL] = uint8(i)
+					numL += b2i(!cmp.Less(x, p))
+				}
+			} else {
+				for i, x := range block {
+					offsetsL[numL] = uint8(i)
+					numL += b2i(!(x < p))
+				}
+			}
+		}
+		if numR == 0 {
+			startR = 0
+			block := data[r-blockSize : r]
+			if strict {
+				for i := range block {
+					offsetsR[numR] = uint8(i)
+					numR += b2i(cmp.Less(block[blockSize-1-i], p))
+				}
+			} else {
+				for i := range block {
+					offsetsR[numR] = uint8(i)
+					numR += b2i(!(p < block[blockSize-1-i]))
+				}
+			}
+		}
+
+		// Rather than swapping pairs, which takes three moves each, move the misplaced
+		// elements around a single cycle: each left slot takes the element from its paired
+		// right slot, which takes the element from the next left slot.
+		if num := min(numL, numR); num > 0 {
+			offL := offsetsL[startL : startL+num]
+			offR := offsetsR[startR : startR+num]
+			left, right := l, r-1
+			tmp := data[left+int(offL[0])]
+			data[left+int(offL[0])] = data[right-int(offR[0])]
+			for i := 1; i < num; i++ {
+				data[right-int(offR[i-1])] = data[left+int(offL[i])]
+				data[left+int(offL[i])] = data[right-int(offR[i])]
+			}
+			data[right-int(offR[num-1])] = tmp
+
+			startL += num
+			startR += num
+			numL -= num
+			numR -= num
+		}
+
+		if numL == 0 {
+			l += blockSize
+		}
+		if numR == 0 {
+			r -= blockSize
+		}
+	}
+
+	// Whatever is left, including the misplaced elements of a block that couldn't be
+	// paired, lies within data[l:r], and is partitioned one element at a time.
+	i, j := l, r-1
+	if strict {
+		for {
+			for i <= j && cmp.Less(data[i], p) {
+				i++
+			}
+			for i <= j && !cmp.Less(data[j], p) {
+				j--
+			}
+			if i > j {
+				return i
+			}
+			data[i], data[j] = data[j], data[i]
+			i++
+			j--
+		}
+	}
+	for {
+		for i <= j && data[i] < p {
+			i++
+		}
+		for i <= j && p < data[j] {
+			j--
+		}
+		if i > j {
+			return i
+		}
+		data[i], data[j] = data[j], data[i]
+		i++
+		j--
+	}
+}
+
+// b2i converts b to 0 or 1 without branching.
+func b2i(b bool) int {
+	var i int
+	if b {
+		i = 1
+	}
+	return i
+}
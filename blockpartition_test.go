@@ -0,0 +1,111 @@
+package kth
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestBlockPartition(t *testing.T) {
+	rng := rand.New(rand.NewPCG(37, 38))
+
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist, BimodalDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder, PushFrontOrder} {
+			for _, size := range []int{1, 2, 255, 256, 257, 1000, 5000} {
+				input := genDistribution(rng, size, dist)
+				applyOrdering(rng, input, order)
+
+				for _, pivot := range []int{0, size / 3, size - 1} {
+					name := fmt.Sprintf("n=%d/pivot=%d/dist=%s/order=%s", size, pivot, dist, order)
+					t.Run(name, func(t *testing.T) {
+						testBlockPartition(t, input, pivot)
+					})
+				}
+			}
+		}
+	}
+
+	t.Run("NaNs", func(t *testing.T) {
+		input := make([]float64, 1000)
+		for i := range input {
+			if rng.IntN(10) == 0 {
+				input[i] = math.NaN()
+			} else {
+				input[i] = rng.Float64()
+			}
+		}
+		for _, pivot := range []int{0, 1, 500} {
+			data := slices.Clone(input)
+			mid, _ := partitionBlockOrdered(data, 0, len(data), pivot)
+			p := data[mid]
+			for i, x := range data {
+				if i < mid && !cmp.Less(x, p) || i > mid && cmp.Less(x, p) {
+					t.Fatalf("pivot=%d: element %v at index %d is on the wrong side of %v at %d", pivot, x, i, p, mid)
+				}
+			}
+		}
+	})
+}
+
+func FuzzBlockPartition(f *testing.F) {
+	f.Add(encodeInts(1, 4, 2, 1), uint16(2))
+	f.Add(encodeInts(5, 5, 5, 5, 5), uint16(0))
+	f.Add(encodeInts(5, 4, 3, 2, 1), uint16(4))
+
+	f.Fuzz(func(t *testing.T, data []byte, pivot uint16) {
+		input := decodeInts(data)
+		if len(input) == 0 {
+			return
+		}
+		testBlockPartition(t, input, int(pivot)%len(input))
+	})
+}
+
+// testBlockPartition checks partitionBlockOrdered against partitionOrdered, and the
+// Hoare flavour of blockPartitionOrdered against the order it must leave data in.
+func testBlockPartition(t *testing.T, input []int, pivot int) {
+	t.Helper()
+
+	want := slices.Clone(input)
+	wantMid, wantPartitioned := partitionOrdered(want, 0, len(want), pivot)
+
+	got := slices.Clone(input)
+	mid, partitioned := partitionBlockOrdered(got, 0, len(got), pivot)
+	if mid != wantMid || partitioned != wantPartitioned {
+		t.Fatalf("partitionBlockOrdered = (%d, %t), want (%d, %t)", mid, partitioned, wantMid, wantPartitioned)
+	}
+	if got[mid] != want[wantMid] {
+		t.Fatalf("pivot = %d, want %d", got[mid], want[wantMid])
+	}
+	for i, x := range got {
+		if i < mid && x >= got[mid] || i > mid && x < got[mid] {
+			t.Fatalf("element %d at index %d is on the wrong side of %d at %d", x, i, got[mid], mid)
+		}
+	}
+	checkSameElements(t, got, input)
+
+	got = slices.Clone(input)
+	p := input[pivot]
+	m := blockPartitionOrdered(got, p, false)
+	for i, x := range got {
+		if i < m && x > p || i >= m && x < p {
+			t.Fatalf("element %d at index %d is on the wrong side of %d at %d", x, i, p, m)
+		}
+	}
+	checkSameElements(t, got, input)
+}
+
+// checkSameElements reports whether got is a permutation of input.
+func checkSameElements(t *testing.T, got, input []int) {
+	t.Helper()
+
+	got, input = slices.Clone(got), slices.Clone(input)
+	slices.Sort(got)
+	slices.Sort(input)
+	if !slices.Equal(got, input) {
+		t.Fatalf("data is not a permutation of the input")
+	}
+}
@@ -0,0 +1,513 @@
+package kth
+
+import (
+	"cmp"
+	"math"
+	"math/bits"
+)
+
+// PDQSelectByKeys is like PDQSelectOrdered, but selects among pairs of elements stored in
+// two parallel slices: keys, which are compared, and vals, which are moved along with
+// them. On return, keys[:k] are the smallest k keys, keys[k-1] is the k-th smallest one,
+// and vals[i] is still the value that was paired with keys[i] before the call.
+//
+// This spares callers with struct-of-arrays layouts an implementation of sort.Interface,
+// which costs an interface call per comparison and per swap.
+// PDQSelectByKeys panics if keys and vals don't have the same length.
+// Like PDQSelect, it leaves both slices untouched when k is not within [1, len(keys)].
+func PDQSelectByKeys[K cmp.Ordered, V any](keys []K, vals []V, k int) {
+	n := checkByKeys(keys, vals)
+	if k < 1 || k > n {
+		return
+	}
+	pdqselectByKeys(keys, vals, 0, n, k-1, bits.Len(uint(n)))
+}
+
+// FloydRivestByKeys is like PDQSelectByKeys, but uses the Floyd-Rivest algorithm,
+// as FloydRivestOrdered does.
+func FloydRivestByKeys[K cmp.Ordered, V any](keys []K, vals []V, k int) {
+	n := checkByKeys(keys, vals)
+	if k < 1 || k > n {
+		return
+	}
+	floydRivestByKeys(keys, vals, 0, n-1, k-1, bits.Len(uint(n)))
+}
+
+// SelectByKeys is like PDQSelectByKeys, but picks the algorithm as SelectOrdered does,
+// probing keys only.
+func SelectByKeys[K cmp.Ordered, V any](keys []K, vals []V, k int) {
+	n := checkByKeys(keys, vals)
+	if k < 1 || k > n {
+		return
+	}
+	switch ChooseAlgorithmOrdered(keys, k) {
+	case FloydRivestAlgorithm:
+		floydRivestByKeys(keys, vals, 0, n-1, k-1, bits.Len(uint(n)))
+	default:
+		pdqselectByKeys(keys, vals, 0, n, k-1, bits.Len(uint(n)))
+	}
+}
+
+// checkByKeys returns the common length of keys and vals, panicking if they differ.
+func checkByKeys[K, V any](keys []K, vals []V) int {
+	if len(keys) != len(vals) {
+		panic("kth: keys and vals have different lengths")
+	}
+	return len(keys)
+}
+
+// swapByKeys swaps the pairs at indices i and j.
+func swapByKeys[K, V any](keys []K, vals []V, i, j int) {
+	keys[i], keys[j] = keys[j], keys[i]
+	vals[i], vals[j] = vals[j], vals[i]
+}
+
+// pdqselectByKeys mirrors pdqselectOrdered, moving vals in lock-step with keys.
+func pdqselectByKeys[K cmp.Ordered, V any](keys []K, vals []V, a, b, k, limit int) {
+	if k == 0 { // Fast path; just find the minimum and place it in a
+		mn := a
+		for i := a + 1; i < b; i++ {
+			if keys[i] < keys[mn] {
+				mn = i
+			}
+		}
+		swapByKeys(keys, vals, a, mn)
+		return
+	}
+
+	if hi := b - 1; k == hi { // Fast path; just find the maximum and place it in b-1
+		mx := a
+		for i := a + 1; i < b; i++ {
+			if keys[i] > keys[mx] {
+				mx = i
+			}
+		}
+		swapByKeys(keys, vals, hi, mx)
+		return
+	}
+
+	const maxInsertion = 12
+
+	var (
+		wasBalanced    = true
+		wasPartitioned = true
+	)
+
+	for {
+		length := b - a
+
+		if length <= maxInsertion {
+			insertionSortByKeys(keys, vals, a, b, cmp.Less[K])
+			return
+		}
+
+		// Fall back to heap select if too many bad choices were made.
+		if limit == 0 {
+			heapSelectByKeys(keys, vals, a, b, k-a, cmp.Less[K])
+			return
+		}
+
+		// Break patterns if the last partitioning was imbalanced
+		if !wasBalanced {
+			breakPatternsByKeys(keys, vals, a, b)
+			limit--
+		}
+
+		// choosePivotOrdered only reads keys, so it can be shared.
+		pivot, hint := choosePivotOrdered(keys, a, b)
+		if hint == decreasingHint {
+			reverseRangeOrdered(keys, a, b)
+			reverseRangeLessFunc(vals, a, b)
+			pivot = (b - 1) - (pivot - a)
+			hint = increasingHint
+		}
+
+		// Check if the slice is likely already sorted
+		if wasBalanced && wasPartitioned && hint == increasingHint {
+			if partialInsertionSortByKeys(keys, vals, a, b, cmp.Less[K]) {
+				return
+			}
+		}
+
+		// Probably the slice contains many duplicate elements, partition the slice into
+		// elements equal to and elements greater than the pivot.
+		if a > 0 && keys[a-1] >= keys[pivot] {
+			mid := partitionEqualByKeys(keys, vals, a, b, pivot, cmp.Less[K])
+			if k < mid {
+				return
+			}
+			a = mid
+			continue
+		}
+
+		var (
+			mid                int
+			alreadyPartitioned bool
+		)
+		if useBlockPartition[K](length) {
+			mid, alreadyPartitioned = partitionBlockByKeys(keys, vals, a, b, pivot)
+		} else {
+			mid, alreadyPartitioned = partitionByKeys(keys, vals, a, b, pivot, cmp.Less[K])
+		}
+		if k == mid {
+			return
+		}
+
+		wasPartitioned = alreadyPartitioned
+		leftLen, rightLen := mid-a, b-mid
+		balanceThreshold := length / 8
+
+		if k < mid {
+			wasBalanced = leftLen >= balanceThreshold
+			b = mid
+		} else {
+			wasBalanced = rightLen >= balanceThreshold
+			a = mid + 1
+		}
+	}
+}
+
+func insertionSortByKeys[K, V any](keys []K, vals []V, a, b int, less func(a, b K) bool) {
+	for i := a + 1; i < b; i++ {
+		for j := i; j > a && less(keys[j], keys[j-1]); j-- {
+			swapByKeys(keys, vals, j, j-1)
+		}
+	}
+}
+
+func heapSelectByKeys[K, V any](keys []K, vals []V, a, b, k int, less func(a, b K) bool) {
+	n := b - a
+	hi := k + 1
+
+	// Build max-heap of first k elements
+	for i := k / 2; i >= 0; i-- {
+		siftDownByKeys(keys, vals, i, hi, a, less)
+	}
+
+	// Process remaining elements
+	for i := hi; i < n; i++ {
+		j := a + i
+		if less(keys[j], keys[a]) {
+			swapByKeys(keys, vals, a, j)
+			siftDownByKeys(keys, vals, 0, hi, a, less)
+		}
+	}
+
+	// Place the k-th element into its final place
+	swapByKeys(keys, vals, a, a+k)
+}
+
+func heapSortByKeys[K, V any](keys []K, vals []V, a, b int, less func(a, b K) bool) {
+	first := a
+	lo := 0
+	hi := b - a
+
+	for i := (hi - 1) / 2; i >= 0; i-- {
+		siftDownByKeys(keys, vals, i, hi, first, less)
+	}
+	for i := hi - 1; i >= 0; i-- {
+		swapByKeys(keys, vals, first, first+i)
+		siftDownByKeys(keys, vals, lo, i, first, less)
+	}
+}
+
+func siftDownByKeys[K, V any](keys []K, vals []V, lo, hi, first int, less func(a, b K) bool) {
+	root := lo
+	for {
+		child := 2*root + 1
+		if child >= hi {
+			return
+		}
+		if child+1 < hi && less(keys[first+child], keys[first+child+1]) {
+			child++
+		}
+		if !less(keys[first+root], keys[first+child]) {
+			return
+		}
+		swapByKeys(keys, vals, first+root, first+child)
+		root = child
+	}
+}
+
+func partitionByKeys[K, V any](keys []K, vals []V, a, b, pivot int, less func(a, b K) bool) (newpivot int, alreadyPartitioned bool) {
+	swapByKeys(keys, vals, a, pivot)
+	i, j := a+1, b-1 // i and j are inclusive of the elements remaining to be partitioned
+
+	for i <= j && less(keys[i], keys[a]) {
+		i++
+	}
+	for i <= j && !less(keys[j], keys[a]) {
+		j--
+	}
+	if i > j {
+		swapByKeys(keys, vals, j, a)
+		return j, true
+	}
+	swapByKeys(keys, vals, i, j)
+	i++
+	j--
+
+	for {
+		for i <= j && less(keys[i], keys[a]) {
+			i++
+		}
+		for i <= j && !less(keys[j], keys[a]) {
+			j--
+		}
+		if i > j {
+			break
+		}
+		swapByKeys(keys, vals, i, j)
+		i++
+		j--
+	}
+	swapByKeys(keys, vals, j, a)
+	return j, false
+}
+
+// partitionBlockByKeys is a drop-in replacement for partitionByKeys that partitions in
+// blocks once it finds a misplaced pair of keys, as partitionBlockOrdered does.
+func partitionBlockByKeys[K cmp.Ordered, V any](keys []K, vals []V, a, b, pivot int) (newpivot int, alreadyPartitioned bool) {
+	swapByKeys(keys, vals, a, pivot)
+	i, j := a+1, b-1 // i and j are inclusive of the elements remaining to be partitioned
+
+	for i <= j && cmp.Less(keys[i], keys[a]) {
+		i++
+	}
+	for i <= j && !cmp.Less(keys[j], keys[a]) {
+		j--
+	}
+	if i > j {
+		swapByKeys(keys, vals, j, a)
+		return j, true
+	}
+
+	// keys[j] is smaller than the pivot, so at least one key ends up left of it.
+	mid := i + blockPartitionByKeys(keys[i:j+1], vals[i:j+1], keys[a]) - 1
+	swapByKeys(keys, vals, mid, a)
+	return mid, false
+}
+
+// blockPartitionByKeys moves the keys smaller than p according to cmp.Less before the
+// others, along with their values, and returns how many there are. It's the strict
+// flavour of blockPartitionOrdered, whose cycles of moves it applies to vals too.
+func blockPartitionByKeys[K cmp.Ordered, V any](keys []K, vals []V, p K) int {
+	var offsetsL, offsetsR [blockSize]uint8
+	var startL, numL, startR, numR int
+	l, r := 0, len(keys) // keys[l:r] remains to be partitioned
+
+	for r-l >= 2*blockSize {
+		if numL == 0 {
+			startL = 0
+			for i, x := range keys[l : l+blockSize] {
+				offsetsL[numL] = uint8(i)
+				numL += b2i(!cmp.Less(x, p))
+			}
+		}
+		if numR == 0 {
+			startR = 0
+			block := keys[r-blockSize : r]
+			for i := range block {
+				offsetsR[numR] = uint8(i)
+				numR += b2i(cmp.Less(block[blockSize-1-i], p))
+			}
+		}
+
+		if num := min(numL, numR); num > 0 {
+			offL := offsetsL[startL : startL+num]
+			offR := offsetsR[startR : startR+num]
+			left, right := l, r-1
+			tmpKey, tmpVal := keys[left+int(offL[0])], vals[left+int(offL[0])]
+			keys[left+int(offL[0])] = keys[right-int(offR[0])]
+			vals[left+int(offL[0])] = vals[right-int(offR[0])]
+			for i := 1; i < num; i++ {
+				keys[right-int(offR[i-1])] = keys[left+int(offL[i])]
+				vals[right-int(offR[i-1])] = vals[left+int(offL[i])]
+				keys[left+int(offL[i])] = keys[right-int(offR[i])]
+				vals[left+int(offL[i])] = vals[right-int(offR[i])]
+			}
+			keys[right-int(offR[num-1])] = tmpKey
+			vals[right-int(offR[num-1])] = tmpVal
+
+			startL += num
+			startR += num
+			numL -= num
+			numR -= num
+		}
+
+		if numL == 0 {
+			l += blockSize
+		}
+		if numR == 0 {
+			r -= blockSize
+		}
+	}
+
+	i, j := l, r-1
+	for {
+		for i <= j && cmp.Less(keys[i], p) {
+			i++
+		}
+		for i <= j && !cmp.Less(keys[j], p) {
+			j--
+		}
+		if i > j {
+			return i
+		}
+		swapByKeys(keys, vals, i, j)
+		i++
+		j--
+	}
+}
+
+func partitionEqualByKeys[K, V any](keys []K, vals []V, a, b, pivot int, less func(a, b K) bool) (newpivot int) {
+	swapByKeys(keys, vals, a, pivot)
+	i, j := a+1, b-1 // i and j are inclusive of the elements remaining to be partitioned
+
+	for {
+		for i <= j && !less(keys[a], keys[i]) {
+			i++
+		}
+		for i <= j && less(keys[a], keys[j]) {
+			j--
+		}
+		if i > j {
+			break
+		}
+		swapByKeys(keys, vals, i, j)
+		i++
+		j--
+	}
+	return i
+}
+
+func partialInsertionSortByKeys[K, V any](keys []K, vals []V, a, b int, less func(a, b K) bool) bool {
+	const (
+		maxSteps         = 5  // maximum number of adjacent out-of-order pairs that will get shifted
+		shortestShifting = 50 // don't shift any elements on short arrays
+	)
+	i := a + 1
+	for j := 0; j < maxSteps; j++ {
+		for i < b && !less(keys[i], keys[i-1]) {
+			i++
+		}
+
+		if i == b {
+			return true
+		}
+
+		if b-a < shortestShifting {
+			return false
+		}
+
+		swapByKeys(keys, vals, i, i-1)
+
+		// Shift the smaller one to the left.
+		if i-a >= 2 {
+			for j := i - 1; j >= 1; j-- {
+				if !less(keys[j], keys[j-1]) {
+					break
+				}
+				swapByKeys(keys, vals, j, j-1)
+			}
+		}
+		// Shift the greater one to the right.
+		if b-i >= 2 {
+			for j := i + 1; j < b; j++ {
+				if !less(keys[j], keys[j-1]) {
+					break
+				}
+				swapByKeys(keys, vals, j, j-1)
+			}
+		}
+	}
+	return false
+}
+
+func breakPatternsByKeys[K, V any](keys []K, vals []V, a, b int) {
+	length := b - a
+	if length >= 8 {
+		random := xorshift(length)
+		modulus := nextPowerOfTwo(length)
+
+		for idx := a + (length/4)*2 - 1; idx <= a+(length/4)*2+1; idx++ {
+			other := int(uint(random.Next()) & (modulus - 1))
+			if other >= length {
+				other -= length
+			}
+			swapByKeys(keys, vals, idx, a+other)
+		}
+	}
+}
+
+// floydRivestByKeys mirrors floydRivestOrdered, moving vals in lock-step with keys.
+func floydRivestByKeys[K cmp.Ordered, V any](keys []K, vals []V, left, right, k, limit int) {
+	for right > left {
+		if limit == 0 {
+			heapSelectByKeys(keys, vals, left, right+1, k-left, cmp.Less[K])
+			return
+		}
+
+		size := right - left
+
+		if size > rangeNarrowingThreshold {
+			n := size + 1
+			i := k - left + 1
+
+			z := math.Log(float64(n))
+			s := 0.5 * math.Exp(2*z/3)
+			sd := 0.5 * math.Sqrt(z*s*(float64(n)-s)/float64(n))
+
+			if i < n/2 {
+				sd *= -1.0
+			}
+
+			newLeft := max(left, int(float64(k)-float64(i)*s/float64(n)+sd))
+			newRight := min(right, int(float64(k)+float64(n-i)*s/float64(n)+sd))
+
+			floydRivestByKeys(keys, vals, newLeft, newRight, k, limit)
+		}
+
+		i, j := left, right
+
+		// Initial pivot selection and positioning
+		swapByKeys(keys, vals, left, k)
+		swap := keys[left] < keys[right]
+		pivot := right
+		if swap {
+			swapByKeys(keys, vals, left, right)
+			pivot = left
+		}
+
+		for i < j {
+			swapByKeys(keys, vals, i, j)
+			i++
+			j--
+
+			for keys[i] < keys[pivot] {
+				i++
+			}
+			for keys[pivot] < keys[j] {
+				j--
+			}
+		}
+
+		if swap {
+			swapByKeys(keys, vals, left, j)
+		} else {
+			j++
+			swapByKeys(keys, vals, right, j)
+		}
+
+		if j <= k {
+			left = j + 1
+		}
+		if k <= j {
+			right = j - 1
+		}
+
+		if right-left > size-size/8 {
+			limit--
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package kth
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestSelectByKeys(t *testing.T) {
+	rng := rand.New(rand.NewPCG(31, 32))
+
+	funcs := []struct {
+		name string
+		fn   func(keys []int, vals []uint64, k int)
+	}{
+		{"PDQSelectByKeys", PDQSelectByKeys[int, uint64]},
+		{"FloydRivestByKeys", FloydRivestByKeys[int, uint64]},
+		{"SelectByKeys", SelectByKeys[int, uint64]},
+	}
+
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist, BimodalDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder, PushFrontOrder} {
+			for _, size := range []int{1, 13, 1000, 50000} {
+				input := genDistribution(rng, size, dist)
+				applyOrdering(rng, input, order)
+				sorted := slices.Clone(input)
+				slices.Sort(sorted)
+
+				for _, k := range []int{1, 2, size / 2, size - 1, size} {
+					k = min(max(k, 1), size)
+					for _, f := range funcs {
+						name := fmt.Sprintf("%s/n=%d/k=%d/dist=%s/order=%s", f.name, size, k, dist, order)
+						t.Run(name, func(t *testing.T) {
+							keys := slices.Clone(input)
+							vals := make([]uint64, size)
+							for i := range vals {
+								vals[i] = uint64(i)
+							}
+
+							f.fn(keys, vals, k)
+							for i, v := range vals {
+								if keys[i] != input[v] {
+									t.Fatalf("key %d at index %d isn't paired with value %d anymore", keys[i], i, v)
+								}
+							}
+							checkSelected(t, keys, sorted, k)
+						})
+					}
+				}
+			}
+		}
+	}
+
+	t.Run("Struct of arrays", func(t *testing.T) {
+		scores := []float32{0.3, 0.9, 0.1, 0.7, 0.5}
+		ids := []uint64{30, 90, 10, 70, 50}
+		SelectByKeys(scores, ids, 2)
+		if !slices.Equal(scores[:2], []float32{0.1, 0.3}) || !slices.Equal(ids[:2], []uint64{10, 30}) {
+			t.Errorf("got scores %v and ids %v", scores, ids)
+		}
+	})
+
+	t.Run("Out of range", func(t *testing.T) {
+		for _, k := range []int{0, 4} {
+			for _, f := range funcs {
+				keys, vals := []int{3, 1, 2}, []uint64{0, 1, 2}
+				f.fn(keys, vals, k)
+				if !slices.Equal(keys, []int{3, 1, 2}) || !slices.Equal(vals, []uint64{0, 1, 2}) {
+					t.Fatalf("%s: k=%d: data was modified: %v, %v", f.name, k, keys, vals)
+				}
+			}
+		}
+	})
+
+	t.Run("Length mismatch", func(t *testing.T) {
+		for _, f := range funcs {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("%s didn't panic", f.name)
+					}
+				}()
+				f.fn([]int{3, 1, 2}, []uint64{0, 1}, 1)
+			}()
+		}
+	})
+}
+
+func BenchmarkSelectByKeys(b *testing.B) {
+	rng := rand.New(rand.NewPCG(42, 42))
+
+	const n = 1_000_000
+	keys := make([]float32, n)
+	vals := make([]uint64, n)
+	for i := range keys {
+		keys[i] = rng.Float32()
+		vals[i] = uint64(i)
+	}
+
+	cases := []struct {
+		name string
+		fn   func(keys []float32, vals []uint64, k int)
+	}{
+		{"PDQSelect", func(keys []float32, vals []uint64, k int) { PDQSelect(scoredIDs{keys, vals}, k) }},
+		// PDQSelectOrdered only moves the keys, which bounds how fast moving the values
+		// along with them can get.
+		{"PDQSelectOrdered", func(keys []float32, _ []uint64, k int) { PDQSelectOrdered(keys, k) }},
+		{"PDQSelectByKeys", PDQSelectByKeys[float32, uint64]},
+		{"FloydRivestByKeys", FloydRivestByKeys[float32, uint64]},
+		{"SelectByKeys", SelectByKeys[float32, uint64]},
+	}
+
+	for _, k := range []int{100, n / 2} {
+		for _, bc := range cases {
+			b.Run(fmt.Sprintf("fn=%s/n=%d/k=%d", bc.name, n, k), func(b *testing.B) {
+				keysCopy := make([]float32, n)
+				valsCopy := make([]uint64, n)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					copy(keysCopy, keys)
+					copy(valsCopy, vals)
+					bc.fn(keysCopy, valsCopy, k)
+				}
+			})
+		}
+	}
+}
+
+// scoredIDs is the sort.Interface that PDQSelectByKeys spares callers from writing.
+type scoredIDs struct {
+	scores []float32
+	ids    []uint64
+}
+
+func (s scoredIDs) Len() int           { return len(s.scores) }
+func (s scoredIDs) Less(i, j int) bool { return s.scores[i] < s.scores[j] }
+func (s scoredIDs) Swap(i, j int) {
+	s.scores[i], s.scores[j] = s.scores[j], s.scores[i]
+	s.ids[i], s.ids[j] = s.ids[j], s.ids[i]
+}
@@ -0,0 +1,104 @@
+package kth
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrKOutOfRange is matched by errors.Is for every *KOutOfRangeError.
+var ErrKOutOfRange = errors.New("kth: k out of range")
+
+// KOutOfRangeError is returned by the checked selection functions when k is
+// not within [1, N], which includes every k when the input is empty.
+type KOutOfRangeError struct {
+	K int // The requested rank.
+	N int // The number of elements in the input.
+}
+
+func (e *KOutOfRangeError) Error() string {
+	if e.N == 0 {
+		return fmt.Sprintf("kth: k=%d out of range: empty input", e.K)
+	}
+	return fmt.Sprintf("kth: k=%d out of range [1, %d]", e.K, e.N)
+}
+
+// Is reports whether target is ErrKOutOfRange.
+func (e *KOutOfRangeError) Is(target error) bool {
+	return target == ErrKOutOfRange
+}
+
+// checkK returns a *KOutOfRangeError if k is not within [1, n].
+func checkK(k, n int) error {
+	if k < 1 || k > n {
+		return &KOutOfRangeError{K: k, N: n}
+	}
+	return nil
+}
+
+// PDQSelectChecked is like PDQSelect, but returns a *KOutOfRangeError instead of
+// silently leaving data untouched when k is not within [1, data.Len()].
+// On success, the k-th smallest element is at index k-1.
+func PDQSelectChecked(data sort.Interface, k int) error {
+	if err := checkK(k, data.Len()); err != nil {
+		return err
+	}
+	PDQSelect(data, k)
+	return nil
+}
+
+// PDQSelectOrderedChecked is like PDQSelectOrdered, but returns the k-th smallest
+// element, or a *KOutOfRangeError when k is not within [1, len(data)].
+func PDQSelectOrderedChecked[T cmp.Ordered](data []T, k int) (T, error) {
+	if err := checkK(k, len(data)); err != nil {
+		var zero T
+		return zero, err
+	}
+	PDQSelectOrdered(data, k)
+	return data[k-1], nil
+}
+
+// PDQSelectFuncChecked is like PDQSelectFunc, but returns the k-th smallest
+// element, or a *KOutOfRangeError when k is not within [1, len(data)].
+func PDQSelectFuncChecked[E any](data []E, k int, less func(a, b E) bool) (E, error) {
+	if err := checkK(k, len(data)); err != nil {
+		var zero E
+		return zero, err
+	}
+	PDQSelectFunc(data, k, less)
+	return data[k-1], nil
+}
+
+// FloydRivestChecked is like FloydRivest, but returns a *KOutOfRangeError instead of
+// silently leaving data untouched when k is not within [1, data.Len()].
+// On success, the k-th smallest element is at index k-1.
+func FloydRivestChecked(data sort.Interface, k int) error {
+	if err := checkK(k, data.Len()); err != nil {
+		return err
+	}
+	FloydRivest(data, k)
+	return nil
+}
+
+// FloydRivestOrderedChecked is like FloydRivestOrdered, but returns the k-th smallest
+// element, or a *KOutOfRangeError when k is not within [1, len(data)].
+func FloydRivestOrderedChecked[T cmp.Ordered](data []T, k int) (T, error) {
+	if err := checkK(k, len(data)); err != nil {
+		var zero T
+		return zero, err
+	}
+	FloydRivestOrdered(data, k)
+	return data[k-1], nil
+}
+
+// FloydRivestFuncChecked is like FloydRivestFunc, but returns the k-th smallest
+// element, or a *KOutOfRangeError when k is not within [1, len(data)].
+func FloydRivestFuncChecked[E any](data []E, k int, less func(a, b E) bool) (E, error) {
+	if err := checkK(k, len(data)); err != nil {
+		var zero E
+		return zero, err
+	}
+	FloydRivestFunc(data, k, less)
+	return data[k-1], nil
+}
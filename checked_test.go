@@ -0,0 +1,123 @@
+package kth
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestSelectOutOfRange(t *testing.T) {
+	funcs := []struct {
+		name string
+		fn   func([]int, int)
+	}{
+		{"PDQSelect", func(data []int, k int) { PDQSelect(sort.IntSlice(data), k) }},
+		{"PDQSelectOrdered", func(data []int, k int) { PDQSelectOrdered(data, k) }},
+		{"PDQSelectFunc", func(data []int, k int) { PDQSelectFunc(data, k, cmp.Less) }},
+		{"FloydRivest", func(data []int, k int) { FloydRivest(sort.IntSlice(data), k) }},
+		{"FloydRivestOrdered", func(data []int, k int) { FloydRivestOrdered(data, k) }},
+		{"FloydRivestFunc", func(data []int, k int) { FloydRivestFunc(data, k, cmp.Less) }},
+	}
+
+	for _, f := range funcs {
+		t.Run(f.name, func(t *testing.T) {
+			for _, k := range []int{-1, 0, 1} {
+				var empty []int
+				f.fn(empty, k) // must not panic
+				f.fn([]int{}, k)
+			}
+
+			input := []int{3, 5, 1, 4, 2}
+			for _, k := range []int{-1, 0, len(input) + 1} {
+				data := slices.Clone(input)
+				f.fn(data, k)
+				if !slices.Equal(data, input) {
+					t.Errorf("k=%d: data was modified: %v", k, data)
+				}
+			}
+
+			data := slices.Clone(input)
+			f.fn(data, len(input))
+			if data[len(data)-1] != 5 {
+				t.Errorf("k=n: largest element not at the end: %v", data)
+			}
+		})
+	}
+}
+
+func TestSelectChecked(t *testing.T) {
+	funcs := []struct {
+		name string
+		fn   func([]int, int) (int, error)
+	}{
+		{"PDQSelectChecked", func(data []int, k int) (int, error) {
+			if err := PDQSelectChecked(sort.IntSlice(data), k); err != nil {
+				return 0, err
+			}
+			return data[k-1], nil
+		}},
+		{"PDQSelectOrderedChecked", PDQSelectOrderedChecked[int]},
+		{"PDQSelectFuncChecked", func(data []int, k int) (int, error) { return PDQSelectFuncChecked(data, k, cmp.Less) }},
+		{"FloydRivestChecked", func(data []int, k int) (int, error) {
+			if err := FloydRivestChecked(sort.IntSlice(data), k); err != nil {
+				return 0, err
+			}
+			return data[k-1], nil
+		}},
+		{"FloydRivestOrderedChecked", FloydRivestOrderedChecked[int]},
+		{"FloydRivestFuncChecked", func(data []int, k int) (int, error) { return FloydRivestFuncChecked(data, k, cmp.Less) }},
+	}
+
+	testCases := []struct {
+		name  string
+		input []int
+		k     int
+		want  int
+		err   bool
+	}{
+		{"Empty", nil, 1, 0, true},
+		{"Empty with k=0", []int{}, 0, 0, true},
+		{"k=0", []int{3, 1, 2}, 0, 0, true},
+		{"Negative k", []int{3, 1, 2}, -2, 0, true},
+		{"k>n", []int{3, 1, 2}, 4, 0, true},
+		{"k=1", []int{3, 1, 2}, 1, 1, false},
+		{"k=n", []int{3, 1, 2}, 3, 3, false},
+		{"Middle", []int{9, 7, 3, 1, 5}, 3, 5, false},
+	}
+
+	for _, f := range funcs {
+		for _, tc := range testCases {
+			t.Run(f.name+"/"+tc.name, func(t *testing.T) {
+				data := slices.Clone(tc.input)
+				got, err := f.fn(data, tc.k)
+
+				if !tc.err {
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					if got != tc.want {
+						t.Errorf("got %d, want %d", got, tc.want)
+					}
+					return
+				}
+
+				if !errors.Is(err, ErrKOutOfRange) {
+					t.Fatalf("error %v does not match ErrKOutOfRange", err)
+				}
+
+				var rangeErr *KOutOfRangeError
+				if !errors.As(err, &rangeErr) {
+					t.Fatalf("error %v is not a *KOutOfRangeError", err)
+				}
+				if rangeErr.K != tc.k || rangeErr.N != len(tc.input) {
+					t.Errorf("error carries k=%d, n=%d; want k=%d, n=%d", rangeErr.K, rangeErr.N, tc.k, len(tc.input))
+				}
+				if !slices.Equal(data, tc.input) {
+					t.Errorf("data was modified: %v", data)
+				}
+			})
+		}
+	}
+}
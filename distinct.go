@@ -0,0 +1,157 @@
+package kth
+
+import (
+	"cmp"
+	"math/bits"
+)
+
+// SelectDistinct swaps elements in the data provided so that the first m elements are
+// the m smallest distinct values in the data, one element for each, and returns m, which
+// is k unless data holds fewer than k distinct values. The duplicates of those values
+// and all greater elements follow in no particular order, and neither is any order
+// among the first m elements guaranteed.
+//
+// It's a quickselect that splits off the elements equal to each pivot, as partitionEqual
+// does for pdqselect, so every run of duplicates collapses into a single value as soon
+// as it's partitioned rather than being deduplicated afterwards, and it only descends
+// into the elements greater than a pivot when fewer than k distinct values are smaller.
+// It takes O(n + p log k) time, where p is the number of elements whose values are
+// among the m selected, and O(n log n) in the worst case. Unlike deduplicating with a
+// map first, it doesn't allocate.
+//
+// NaNs are treated as a single value, smaller than any other, like cmp.Compare does.
+// SelectDistinct returns 0 without touching data when k < 1.
+func SelectDistinct[T cmp.Ordered](data []T, k int) int {
+	n := len(data)
+	if k < 1 || n == 0 {
+		return 0
+	}
+	return selectDistinctOrdered(data, 0, n, min(k, n), bits.Len(uint(n)))
+}
+
+// SelectDistinctFunc is a generic version of SelectDistinct that allows the caller to
+// provide a custom comparison function to determine the order of elements. Two elements
+// are the same value when neither is less than the other.
+func SelectDistinctFunc[E any](data []E, k int, less func(a, b E) bool) int {
+	n := len(data)
+	if k < 1 || n == 0 {
+		return 0
+	}
+	return selectDistinctLessFunc(data, 0, n, min(k, n), bits.Len(uint(n)), less)
+}
+
+// selectDistinctOrdered moves the k smallest distinct values of data[a:b], or all of
+// them if there are fewer, to data[a:a+m] and returns m. After limit imbalanced
+// partitions, it sorts what's left to search instead.
+func selectDistinctOrdered[E cmp.Ordered](data []E, a, b, k, limit int) int {
+	const maxInsertion = 12
+
+	// data[a:a+m] holds the distinct values found so far, and data[a+m:lo] duplicates
+	// of them. Everything within data[lo:b] is greater.
+	m, lo := 0, a
+	for {
+		length := b - lo
+		if length <= maxInsertion {
+			insertionSortOrdered(data, lo, b)
+			return m + compactDistinctOrdered(data, a+m, lo, b, k-m)
+		}
+		if limit == 0 {
+			heapSortOrdered(data, lo, b)
+			return m + compactDistinctOrdered(data, a+m, lo, b, k-m)
+		}
+
+		pivot, _ := choosePivotOrdered(data, lo, b)
+		var mid int
+		if useBlockPartition[E](length) {
+			mid, _ = partitionBlockOrdered(data, lo, b, pivot)
+		} else {
+			mid, _ = partitionOrdered(data, lo, b, pivot)
+		}
+		if min(mid-lo, b-mid) < length/8 {
+			limit--
+		}
+
+		// The distinct values smaller than the pivot come first. The recursion places them
+		// at data[lo:], so they are moved down next to those found before.
+		l := selectDistinctOrdered(data, lo, mid, k-m, limit)
+		for i := range l {
+			data[a+m+i], data[lo+i] = data[lo+i], data[a+m+i]
+		}
+		if m += l; m == k {
+			return m
+		}
+
+		// They weren't enough, so the pivot is the next distinct value. Its duplicates are
+		// set aside, and the search carries on with the greater elements.
+		end := partitionEqualOrdered(data, mid, b, mid)
+		data[a+m], data[mid] = data[mid], data[a+m]
+		if m++; m == k {
+			return m
+		}
+		lo = end
+	}
+}
+
+// compactDistinctOrdered moves the first k distinct values of data[a:b], which is sorted,
+// to data[dst:dst+c], where dst <= a, and returns c.
+func compactDistinctOrdered[E cmp.Ordered](data []E, dst, a, b, k int) int {
+	c := 0
+	for i := a; i < b && c < k; i++ {
+		if c == 0 || cmp.Less(data[dst+c-1], data[i]) {
+			data[dst+c], data[i] = data[i], data[dst+c]
+			c++
+		}
+	}
+	return c
+}
+
+// selectDistinctLessFunc is the less function counterpart of selectDistinctOrdered.
+func selectDistinctLessFunc[E any](data []E, a, b, k, limit int, less func(a, b E) bool) int {
+	const maxInsertion = 12
+
+	m, lo := 0, a
+	for {
+		length := b - lo
+		if length <= maxInsertion {
+			insertionSortLessFunc(data, lo, b, less)
+			return m + compactDistinctLessFunc(data, a+m, lo, b, k-m, less)
+		}
+		if limit == 0 {
+			heapSortLessFunc(data, lo, b, less)
+			return m + compactDistinctLessFunc(data, a+m, lo, b, k-m, less)
+		}
+
+		pivot, _ := choosePivotLessFunc(data, lo, b, less)
+		mid, _ := partitionLessFunc(data, lo, b, pivot, less)
+		if min(mid-lo, b-mid) < length/8 {
+			limit--
+		}
+
+		l := selectDistinctLessFunc(data, lo, mid, k-m, limit, less)
+		for i := range l {
+			data[a+m+i], data[lo+i] = data[lo+i], data[a+m+i]
+		}
+		if m += l; m == k {
+			return m
+		}
+
+		end := partitionEqualLessFunc(data, mid, b, mid, less)
+		data[a+m], data[mid] = data[mid], data[a+m]
+		if m++; m == k {
+			return m
+		}
+		lo = end
+	}
+}
+
+// compactDistinctLessFunc is the less function counterpart of compactDistinctOrdered.
+func compactDistinctLessFunc[E any](data []E, dst, a, b, k int, less func(a, b E) bool) int {
+	c := 0
+	for i := a; i < b && c < k; i++ {
+		if c == 0 || less(data[dst+c-1], data[i]) {
+			data[dst+c], data[i] = data[i], data[dst+c]
+			c++
+		}
+	}
+	return c
+}
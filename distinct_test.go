@@ -0,0 +1,100 @@
+package kth
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestSelectDistinct(t *testing.T) {
+	rng := rand.New(rand.NewPCG(41, 42))
+
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist, BimodalDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder, MostlySorted} {
+			for _, size := range []int{1, 13, 300, 5000} {
+				input := genDistribution(rng, size, dist)
+				applyOrdering(rng, input, order)
+
+				for _, k := range []int{1, 2, 10, size / 2, size, size + 1} {
+					name := fmt.Sprintf("n=%d/k=%d/dist=%s/order=%s", size, k, dist, order)
+					t.Run(name, func(t *testing.T) {
+						testSelectDistinct(t, input, k, "SelectDistinct", SelectDistinct[int])
+						testSelectDistinct(t, input, k, "SelectDistinctFunc", func(data []int, k int) int {
+							return SelectDistinctFunc(data, k, func(a, b int) bool { return a < b })
+						})
+					})
+				}
+			}
+		}
+	}
+
+	t.Run("Few values", func(t *testing.T) {
+		// Many duplicates of few values, the case SelectDistinct is for.
+		input := make([]int, 100000)
+		for i := range input {
+			input[i] = rng.IntN(50) * 7
+		}
+		for _, k := range []int{1, 10, 49, 50, 51} {
+			testSelectDistinct(t, input, k, "SelectDistinct", SelectDistinct[int])
+		}
+	})
+
+	t.Run("NaNs", func(t *testing.T) {
+		data := []float64{3, math.NaN(), 1, 3, math.NaN(), 2, 1}
+		m := SelectDistinct(data, 3)
+		got := slices.Clone(data[:m])
+		slices.SortFunc(got, cmp.Compare[float64])
+		if m != 3 || !math.IsNaN(got[0]) || got[1] != 1 || got[2] != 2 {
+			t.Fatalf("SelectDistinct = %d, %v, want 3, [NaN 1 2]", m, got)
+		}
+	})
+
+	t.Run("Out of range", func(t *testing.T) {
+		data := []int{3, 1, 2}
+		if m := SelectDistinct(data, 0); m != 0 || !slices.Equal(data, []int{3, 1, 2}) {
+			t.Fatalf("SelectDistinct(data, 0) = %d, data = %v", m, data)
+		}
+		if m := SelectDistinct([]int(nil), 3); m != 0 {
+			t.Fatalf("SelectDistinct(nil, 3) = %d, want 0", m)
+		}
+	})
+}
+
+func FuzzSelectDistinct(f *testing.F) {
+	f.Add(encodeInts(1, 4, 2, 1, 4, 4), uint16(2))
+	f.Add(encodeInts(5, 5, 5, 5, 5), uint16(3))
+	f.Add(encodeInts(5, 4, 3, 2, 1), uint16(5))
+
+	f.Fuzz(func(t *testing.T, data []byte, k uint16) {
+		input := decodeInts(data)
+		if len(input) == 0 {
+			return
+		}
+		testSelectDistinct(t, input, int(k)%(len(input)+1)+1, "SelectDistinct", SelectDistinct[int])
+	})
+}
+
+// testSelectDistinct checks selectDistinct against deduplicating a sorted copy of input.
+func testSelectDistinct(t *testing.T, input []int, k int, name string, selectDistinct func([]int, int) int) {
+	t.Helper()
+
+	want := slices.Clone(input)
+	slices.Sort(want)
+	want = slices.Compact(want)
+	want = want[:min(k, len(want))]
+
+	data := slices.Clone(input)
+	m := selectDistinct(data, k)
+	if m != len(want) {
+		t.Fatalf("%s: returned %d, want %d", name, m, len(want))
+	}
+	got := slices.Clone(data[:m])
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Fatalf("%s: first %d elements = %v, want %v", name, m, got, want)
+	}
+	checkSameElements(t, data, input)
+}
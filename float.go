@@ -0,0 +1,144 @@
+package kth
+
+import "math"
+
+// Float is a constraint that permits any floating-point type.
+type Float interface {
+	~float32 | ~float64
+}
+
+// NaNPolicy defines where the float selection functions order NaNs.
+//
+// Whatever the policy, the other values follow IEEE 754's totalOrder predicate:
+// -Inf sorts first and +Inf last, and -0 sorts right before +0 rather than being
+// equal to it. All NaNs are equal to each other, regardless of their sign and payload.
+type NaNPolicy int
+
+const (
+	// NaNsFirst orders NaNs before every other value, as cmp.Compare and slices.Sort do.
+	NaNsFirst NaNPolicy = iota
+
+	// NaNsLast orders NaNs after every other value.
+	NaNsLast
+
+	// NaNsExcluded leaves NaNs out of the selection: they're moved to the end of the
+	// data, and k is a rank among the other values only.
+	NaNsExcluded
+)
+
+// PDQSelectFloat is a version of PDQSelectOrdered for floating-point types that follows
+// a well defined order, unlike the < operator, which isn't a strict weak order in the
+// presence of NaNs and doesn't tell -0 apart from +0. See NaNPolicy for that order.
+//
+// On return, the first k elements are the smallest k elements of data according to it,
+// with the k-th smallest at index k-1. With NaNsExcluded, only the first len(data)-nans
+// elements take part in the selection, and the NaNs are moved after them. PDQSelectFloat
+// returns the number of NaNs in data.
+//
+// It first moves the NaNs to one end of data and then runs PDQSelectOrdered over the
+// other values, which costs an extra pass. Another pass orders the zeros by sign when
+// the k-th smallest value is a zero. Like PDQSelect, it leaves data untouched when k is
+// not within [1, len(data)], or [1, len(data)-nans] with NaNsExcluded.
+func PDQSelectFloat[T Float](data []T, k int, policy NaNPolicy) (nans int) {
+	return selectFloat(data, k, policy, PDQSelectOrdered[T])
+}
+
+// FloydRivestFloat is like PDQSelectFloat, but runs FloydRivestOrdered over the values
+// that aren't NaNs.
+func FloydRivestFloat[T Float](data []T, k int, policy NaNPolicy) (nans int) {
+	return selectFloat(data, k, policy, FloydRivestOrdered[T])
+}
+
+// SelectFloat is like PDQSelectFloat, but runs SelectOrdered over the values that aren't
+// NaNs, which picks the algorithm according to them.
+func SelectFloat[T Float](data []T, k int, policy NaNPolicy) (nans int) {
+	return selectFloat(data, k, policy, SelectOrdered[T])
+}
+
+// selectFloat gathers the NaNs of data at the end its policy puts them at, and selects
+// the k-th smallest element among the others with selectOrdered, if k doesn't fall on
+// a NaN.
+func selectFloat[T Float](data []T, k int, policy NaNPolicy, selectOrdered func([]T, int)) int {
+	n := len(data)
+	if k < 1 || k > n {
+		return countNaNs(data)
+	}
+	if policy == NaNsExcluded {
+		if nans := countNaNs(data); k > n-nans {
+			return nans
+		}
+	}
+
+	var nans int
+	if policy == NaNsFirst {
+		for i, x := range data {
+			if x != x {
+				data[i], data[nans] = data[nans], data[i]
+				nans++
+			}
+		}
+		if k <= nans {
+			return nans
+		}
+		selectOrdered(data[nans:], k-nans)
+		orderZeros(data[nans:], k-nans)
+		return nans
+	}
+
+	m := n
+	for i := n - 1; i >= 0; i-- {
+		if x := data[i]; x != x {
+			m--
+			data[i], data[m] = data[m], data[i]
+		}
+	}
+	if k > m {
+		return n - m
+	}
+	selectOrdered(data[:m], k)
+	orderZeros(data[:m], k)
+	return n - m
+}
+
+// countNaNs returns the number of NaNs in data.
+func countNaNs[T Float](data []T) int {
+	nans := 0
+	for _, x := range data {
+		if x != x {
+			nans++
+		}
+	}
+	return nans
+}
+
+// orderZeros moves the negative zeros of data before its positive zeros if data[k-1]
+// is a zero, given that data was partitioned around it by an algorithm that considers
+// both zeros equal. It first gathers all zeros around index k-1, which keeps data
+// partitioned since they are the largest elements of data[:k-1] and the smallest of
+// data[k:].
+func orderZeros[T Float](data []T, k int) {
+	if data[k-1] != 0 {
+		return
+	}
+
+	lo, hi := k-1, k
+	for i := k - 2; i >= 0; i-- {
+		if data[i] == 0 {
+			lo--
+			data[i], data[lo] = data[lo], data[i]
+		}
+	}
+	for i := k; i < len(data); i++ {
+		if data[i] == 0 {
+			data[i], data[hi] = data[hi], data[i]
+			hi++
+		}
+	}
+
+	for i := lo; i < hi; i++ {
+		if math.Signbit(float64(data[i])) {
+			data[i], data[lo] = data[lo], data[i]
+			lo++
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package kth
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestSelectFloat(t *testing.T) {
+	rng := rand.New(rand.NewPCG(33, 34))
+
+	specials := []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1), 0}
+	for _, size := range []int{1, 10, 100, 1000, 40000} {
+		for _, mix := range []float64{0, 0.01, 0.3, 0.9, 1} {
+			input := make([]float64, size)
+			for i := range input {
+				if rng.Float64() < mix {
+					input[i] = specials[rng.IntN(len(specials))]
+				} else {
+					input[i] = float64(rng.IntN(size) - size/2)
+				}
+			}
+
+			for _, k := range []int{1, 2, size / 2, size - 1, size} {
+				k = min(max(k, 1), size)
+				for _, policy := range []NaNPolicy{NaNsFirst, NaNsLast, NaNsExcluded} {
+					name := fmt.Sprintf("n=%d/mix=%v/k=%d/policy=%d", size, mix, k, policy)
+					t.Run(name, func(t *testing.T) {
+						testSelectFloat(t, input, k, policy)
+					})
+				}
+			}
+		}
+	}
+
+	t.Run("float32", func(t *testing.T) {
+		data := []float32{3, float32(math.NaN()), 0, float32(math.Copysign(0, -1)), -1}
+		if nans := PDQSelectFloat(data, 3, NaNsLast); nans != 1 {
+			t.Errorf("nans = %d, want 1", nans)
+		}
+		if x := data[2]; x != 0 || math.Signbit(float64(x)) {
+			t.Errorf("3rd smallest = %v, want +0", x)
+		}
+		if x := data[1]; x != 0 || !math.Signbit(float64(x)) {
+			t.Errorf("2nd smallest = %v, want -0", x)
+		}
+	})
+
+	t.Run("Out of range", func(t *testing.T) {
+		input := []float64{3, math.NaN(), 1, math.NaN()}
+		for _, tc := range []struct {
+			k      int
+			policy NaNPolicy
+		}{{0, NaNsFirst}, {5, NaNsLast}, {3, NaNsExcluded}} {
+			data := slices.Clone(input)
+			if nans := SelectFloat(data, tc.k, tc.policy); nans != 2 {
+				t.Errorf("k=%d: nans = %d, want 2", tc.k, nans)
+			}
+			if !slices.EqualFunc(data, input, sameFloat) {
+				t.Errorf("k=%d: data was modified: %v", tc.k, data)
+			}
+		}
+	})
+}
+
+func FuzzSelectFloat(f *testing.F) {
+	nan, inf, negZero := math.NaN(), math.Inf(1), math.Copysign(0, -1)
+	f.Add(encodeFloats(nan, 1, nan, 0), uint16(2), uint8(NaNsFirst))
+	f.Add(encodeFloats(0, negZero, 0, negZero, 1, -1), uint16(3), uint8(NaNsLast))
+	f.Add(encodeFloats(inf, -inf, nan, negZero, 0, 2), uint16(4), uint8(NaNsExcluded))
+	f.Add(encodeFloats(nan, nan, nan), uint16(1), uint8(NaNsExcluded))
+
+	f.Fuzz(func(t *testing.T, data []byte, k uint16, policy uint8) {
+		input := decodeFloats(data)
+		if len(input) == 0 {
+			return
+		}
+		testSelectFloat(t, input, 1+int(k)%len(input), NaNPolicy(policy%3))
+	})
+}
+
+// testSelectFloat checks the float selection functions against a sorted copy of input.
+func testSelectFloat(t *testing.T, input []float64, k int, policy NaNPolicy) {
+	t.Helper()
+
+	nans := 0
+	var values []float64
+	for _, x := range input {
+		if math.IsNaN(x) {
+			nans++
+		} else {
+			values = append(values, x)
+		}
+	}
+	slices.SortFunc(values, totalCompare)
+
+	nanValues := make([]float64, nans)
+	for i := range nanValues {
+		nanValues[i] = math.NaN()
+	}
+	var want []float64
+	switch policy {
+	case NaNsFirst:
+		want = slices.Concat(nanValues, values)
+	case NaNsLast:
+		want = slices.Concat(values, nanValues)
+	case NaNsExcluded:
+		want = values
+	}
+
+	for _, fn := range []struct {
+		name string
+		fn   func([]float64, int, NaNPolicy) int
+	}{
+		{"PDQSelectFloat", PDQSelectFloat[float64]},
+		{"FloydRivestFloat", FloydRivestFloat[float64]},
+		{"SelectFloat", SelectFloat[float64]},
+	} {
+		data := slices.Clone(input)
+		got := fn.fn(data, k, policy)
+		if got != nans {
+			t.Fatalf("%s: nans = %d, want %d", fn.name, got, nans)
+		}
+
+		if k > len(want) {
+			if !slices.EqualFunc(data, input, sameFloat) {
+				t.Fatalf("%s: data was modified with k out of range", fn.name)
+			}
+			continue
+		}
+
+		selected := data[:len(want)]
+		if kth := selected[k-1]; !sameFloat(kth, want[k-1]) {
+			t.Fatalf("%s: k-th element = %v, want %v", fn.name, kth, want[k-1])
+		}
+		for i, x := range selected {
+			if c := compareWithNaNs(x, want[k-1], policy); i < k && c > 0 || i >= k && c < 0 {
+				t.Fatalf("%s: element %v at index %d is on the wrong side of %v", fn.name, x, i, want[k-1])
+			}
+		}
+		for _, x := range data[len(want):] {
+			if !math.IsNaN(x) {
+				t.Fatalf("%s: %v was found among the excluded NaNs", fn.name, x)
+			}
+		}
+		slices.SortFunc(selected, func(a, b float64) int { return compareWithNaNs(a, b, policy) })
+		if !slices.EqualFunc(selected, want, sameFloat) {
+			t.Fatalf("%s: data is not a permutation of the input", fn.name)
+		}
+	}
+}
+
+// totalCompare compares non-NaN floats, ordering -0 before +0.
+func totalCompare(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	case math.Signbit(a) && !math.Signbit(b):
+		return -1
+	case !math.Signbit(a) && math.Signbit(b):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareWithNaNs(a, b float64, policy NaNPolicy) int {
+	nanOrder := 1
+	if policy == NaNsFirst {
+		nanOrder = -1
+	}
+	switch an, bn := math.IsNaN(a), math.IsNaN(b); {
+	case an && bn:
+		return 0
+	case an:
+		return nanOrder
+	case bn:
+		return -nanOrder
+	}
+	return totalCompare(a, b)
+}
+
+// sameFloat reports whether a and b are both NaN, or are equal and have the same sign.
+func sameFloat(a, b float64) bool {
+	return math.IsNaN(a) && math.IsNaN(b) || a == b && math.Signbit(a) == math.Signbit(b)
+}
+
+func encodeFloats(floats ...float64) []byte {
+	data := make([]byte, 8*len(floats))
+	for i, x := range floats {
+		binary.LittleEndian.PutUint64(data[8*i:], math.Float64bits(x))
+	}
+	return data
+}
+
+func decodeFloats(data []byte) []float64 {
+	floats := make([]float64, len(data)/8)
+	for i := range floats {
+		floats[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+	return floats
+}
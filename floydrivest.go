@@ -3,18 +3,27 @@ package kth
 import (
 	"cmp"
 	"math"
+	"math/bits"
 	"sort"
 )
 
 // FloydRivest implements the Floyd-Rivest selection algorithm to find the k-th smallest elements.
 // It typically makes fewer comparisons than other selection algorithms by narrowing the search range
 // based on order statistics estimates before partitioning.
+//
+// Inputs crafted so that range narrowing keeps failing are detected after O(log n)
+// unproductive partitioning steps, at which point FloydRivest falls back to heap
+// selection. This bounds its worst case to O(n log n) comparisons.
+//
+// k must be within [1, n], with k == n placing the largest element at index n-1.
+// Otherwise, which is always the case for empty data, FloydRivest returns without
+// touching data. Use FloydRivestChecked to get an error instead.
 func FloydRivest(data sort.Interface, k int) {
 	n := data.Len()
 	if k < 1 || k > n {
 		return
 	}
-	floydRivest(data, 0, n-1, k-1)
+	floydRivest(data, 0, n-1, k-1, bits.Len(uint(n)))
 }
 
 // rangeNarrowingThreshold represents the size above which we narrow the search range
@@ -29,9 +38,14 @@ const rangeNarrowingThreshold = 600
 // The algorithm combines two strategies with proven optimality:
 // - Range narrowing based on order statistics for large arrays
 // - Efficient partitioning for reduced ranges
-func floydRivest(data sort.Interface, left, right, k int) {
+func floydRivest(data sort.Interface, left, right, k, limit int) {
 	// Loop invariant: k-th element is within [left, right]
 	for right > left {
+		if limit == 0 {
+			heapSelect(data, left, right+1, k-left)
+			return
+		}
+
 		size := right - left
 
 		// For large arrays, attempt to narrow the search range
@@ -58,7 +72,7 @@ func floydRivest(data sort.Interface, left, right, k int) {
 			newLeft := max(left, int(float64(k)-float64(i)*s/float64(n)+sd))
 			newRight := min(right, int(float64(k)+float64(n-i)*s/float64(n)+sd))
 
-			floydRivest(data, newLeft, newRight, k)
+			floydRivest(data, newLeft, newRight, k, limit)
 		}
 
 		// Partitioning section
@@ -132,23 +146,47 @@ func floydRivest(data sort.Interface, left, right, k int) {
 		if k <= j {
 			right = j - 1
 		}
+
+		// Range narrowing relies on the distribution of the sample matching the one of
+		// the data, which crafted inputs can break so that each partitioning step only
+		// discards a few elements. Like pdqselect, we tolerate up to log2(n) steps that
+		// keep more than 7/8 of the range before falling back to heapSelect, which bounds
+		// the running time to O(n log n).
+		if right-left > size-size/8 {
+			limit--
+		}
 	}
 }
 
 // FloydRivestOrdered is a specialized version of FloydRivest that works with slices of
 // ordered types (i.e. types that implement the cmp.Ordered interface).
+// Like FloydRivest, it leaves data untouched when k is not within [1, len(data)].
 func FloydRivestOrdered[T cmp.Ordered](data []T, k int) {
 	n := len(data)
 	if k < 1 || k > n {
 		return
 	}
-	floydRivestOrdered(data, 0, n-1, k-1)
+	floydRivestOrdered(data, 0, n-1, k-1, bits.Len(uint(n)))
 }
 
-func floydRivestOrdered[T cmp.Ordered](data []T, left, right, k int) {
+func floydRivestOrdered[T cmp.Ordered](data []T, left, right, k, limit int) {
 	for right > left {
+		if limit == 0 {
+			heapSelectOrdered(data, left, right+1, k-left)
+			return
+		}
+
 		size := right - left
 
+		// Look for a range sorted either way before narrowing it down disturbs its middle.
+		// Its comparisons are predictable, so partitioning it in blocks would only slow
+		// it down.
+		blocks := useBlockPartition[T](size + 1)
+		if blocks {
+			_, hint := choosePivotOrdered(data, left, right+1)
+			blocks = hint == unknownHint
+		}
+
 		if size > rangeNarrowingThreshold {
 			n := size + 1
 			i := k - left + 1
@@ -164,61 +202,131 @@ func floydRivestOrdered[T cmp.Ordered](data []T, left, right, k int) {
 			newLeft := max(left, int(float64(k)-float64(i)*s/float64(n)+sd))
 			newRight := min(right, int(float64(k)+float64(n-i)*s/float64(n)+sd))
 
-			floydRivestOrdered(data, newLeft, newRight, k)
+			floydRivestOrdered(data, newLeft, newRight, k, limit)
 		}
 
-		i, j := left, right
+		j := partitionFloydRivestOrdered(data, left, right, k, blocks)
 
-		// Initial pivot selection and positioning
-		data[left], data[k] = data[k], data[left]
-		swap := data[left] < data[right]
-		pivot := right
-		if swap {
-			data[left], data[right] = data[right], data[left]
-			pivot = left
+		if j <= k {
+			left = j + 1
+		}
+		if k <= j {
+			right = j - 1
 		}
 
-		for i < j {
-			data[i], data[j] = data[j], data[i]
+		if right-left > size-size/8 {
+			limit--
+		}
+	}
+}
+
+// partitionFloydRivestOrdered partitions data[left:right+1] around data[k], and returns
+// the index it ends up at, with no greater element before it and no smaller one after.
+// It partitions in blocks if blocks is set, unless k is an extreme rank.
+func partitionFloydRivestOrdered[T cmp.Ordered](data []T, left, right, k int, blocks bool) int {
+	// Initial pivot selection and positioning
+	data[left], data[k] = data[k], data[left]
+
+	// The comparisons of a partition around an extreme rank are predictable enough on
+	// their own, as most elements end up on the same side.
+	if size := right - left + 1; blocks && min(k-left, right-k) >= size/16 {
+		t := data[left]
+		i, j := left+1, right
+		for i <= j && data[i] < t {
 			i++
+		}
+		for i <= j && t < data[j] {
 			j--
-
-			for data[i] < data[pivot] {
-				i++
-			}
-			for data[pivot] < data[j] {
-				j--
-			}
 		}
+		j = i + blockPartitionOrdered(data[i:j+1], t, false) - 1
+		data[left], data[j] = data[j], data[left]
+		return j
+	}
 
-		if swap {
-			data[left], data[j] = data[j], data[left]
-		} else {
-			j++
-			data[right], data[j] = data[j], data[right]
-		}
+	i, j := left, right
+	swap := data[left] < data[right]
+	pivot := right
+	if swap {
+		data[left], data[right] = data[right], data[left]
+		pivot = left
+	}
 
-		if j <= k {
-			left = j + 1
+	for i < j {
+		data[i], data[j] = data[j], data[i]
+		i++
+		j--
+
+		for data[i] < data[pivot] {
+			i++
 		}
-		if k <= j {
-			right = j - 1
+		for data[pivot] < data[j] {
+			j--
 		}
 	}
+
+	if swap {
+		data[left], data[j] = data[j], data[left]
+	} else {
+		j++
+		data[right], data[j] = data[j], data[right]
+	}
+	return j
 }
 
 // FloydRivestFunc is a generic version of FloydRivest that allows the caller to provide
 // a custom comparison function to determine the order of elements.
+// Like FloydRivest, it leaves data untouched when k is not within [1, len(data)].
 func FloydRivestFunc[E any](data []E, k int, less func(a, b E) bool) {
 	n := len(data)
 	if k < 1 || k > n {
 		return
 	}
-	floydRivestFunc(data, 0, n-1, k-1, less)
+	floydRivestFunc(data, 0, n-1, k-1, bits.Len(uint(n)), less)
 }
 
-func floydRivestFunc[E any](data []E, left, right, k int, less func(a, b E) bool) {
+// FloydRivestWithin is like FloydRivest, but only considers the elements in the window
+// data[a:b], leaving everything outside of it untouched. This is useful for types
+// that can't cheaply be re-sliced, such as struct-of-arrays layouts.
+//
+// k is global: it's a 1-based position within data rather than within the window,
+// so the element that would be at index k-1 if data[a:b] was sorted ends up there,
+// with no greater element in data[a:k-1] and no smaller one in data[k:b]. This makes
+// FloydRivestWithin(data, 0, n, k) equivalent to FloydRivest(data, k).
+//
+// The window must satisfy 0 <= a < b <= n and k must be within [a+1, b]. Otherwise,
+// FloydRivestWithin returns without touching data.
+func FloydRivestWithin(data sort.Interface, a, b, k int) {
+	if a < 0 || b > data.Len() || k <= a || k > b {
+		return
+	}
+	floydRivest(data, a, b-1, k-1, bits.Len(uint(b-a)))
+}
+
+// FloydRivestWithinOrdered is a specialized version of FloydRivestWithin that works with slices of
+// ordered types (i.e. types that implement the cmp.Ordered interface).
+func FloydRivestWithinOrdered[T cmp.Ordered](data []T, a, b, k int) {
+	if a < 0 || b > len(data) || k <= a || k > b {
+		return
+	}
+	floydRivestOrdered(data, a, b-1, k-1, bits.Len(uint(b-a)))
+}
+
+// FloydRivestWithinFunc is a generic version of FloydRivestWithin that allows the caller to provide
+// a custom comparison function to determine the order of elements.
+func FloydRivestWithinFunc[E any](data []E, a, b, k int, less func(a, b E) bool) {
+	if a < 0 || b > len(data) || k <= a || k > b {
+		return
+	}
+	floydRivestFunc(data, a, b-1, k-1, bits.Len(uint(b-a)), less)
+}
+
+func floydRivestFunc[E any](data []E, left, right, k, limit int, less func(a, b E) bool) {
 	for right > left {
+		if limit == 0 {
+			heapSelectFunc(data, left, right+1, k-left, less)
+			return
+		}
+
 		size := right - left
 
 		if size > rangeNarrowingThreshold {
@@ -236,7 +344,7 @@ func floydRivestFunc[E any](data []E, left, right, k int, less func(a, b E) bool
 			newLeft := max(left, int(float64(k)-float64(i)*s/float64(n)+sd))
 			newRight := min(right, int(float64(k)+float64(n-i)*s/float64(n)+sd))
 
-			floydRivestFunc(data, newLeft, newRight, k, less)
+			floydRivestFunc(data, newLeft, newRight, k, limit, less)
 		}
 
 		i, j := left, right
@@ -276,5 +384,111 @@ func floydRivestFunc[E any](data []E, left, right, k int, less func(a, b E) bool
 		if k <= j {
 			right = j - 1
 		}
+
+		if right-left > size-size/8 {
+			limit--
+		}
+	}
+}
+
+// FloydRivestCmpFunc is like FloydRivestFunc, but takes a three-way comparison function
+// as used by slices.SortFunc and slices.BinarySearchFunc, returning a negative number
+// when a < b, a positive number when a > b and zero when a == b.
+// Like FloydRivest, it leaves data untouched when k is not within [1, len(data)].
+//
+// The three-way result lets each partitioning step route every element to the band
+// below, equal to or above the pivot with a single call, and stop as soon as the k-th
+// element falls into the equal band. This makes it much faster than FloydRivestFunc
+// on data with many duplicates.
+func FloydRivestCmpFunc[E any](data []E, k int, cmp func(a, b E) int) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	floydRivestCmpFunc(data, 0, n-1, k-1, bits.Len(uint(n)), cmp)
+}
+
+func floydRivestCmpFunc[E any](data []E, left, right, k, limit int, cmp func(a, b E) int) {
+	for right > left {
+		if limit == 0 {
+			heapSelectCmpFunc(data, left, right+1, k-left, cmp)
+			return
+		}
+
+		size := right - left
+
+		if size > rangeNarrowingThreshold {
+			n := size + 1
+			i := k - left + 1
+
+			z := math.Log(float64(n))
+			s := 0.5 * math.Exp(2*z/3)
+			sd := 0.5 * math.Sqrt(z*s*(float64(n)-s)/float64(n))
+
+			if i < n/2 {
+				sd *= -1.0
+			}
+
+			newLeft := max(left, int(float64(k)-float64(i)*s/float64(n)+sd))
+			newRight := min(right, int(float64(k)+float64(n-i)*s/float64(n)+sd))
+
+			floydRivestCmpFunc(data, newLeft, newRight, k, limit, cmp)
+		}
+
+		// Bentley-McIlroy three-way partitioning around t. Elements equal to t are
+		// parked at both ends while scanning:
+		//    [left, p) == t, [p, i) < t, (j, q] > t, (q, right] == t
+		t := data[k]
+		i, j := left, right
+		p, q := left, right
+		for {
+			for ; i <= j; i++ {
+				c := cmp(data[i], t)
+				if c > 0 {
+					break
+				}
+				if c == 0 {
+					data[p], data[i] = data[i], data[p]
+					p++
+				}
+			}
+			for ; i <= j; j-- {
+				c := cmp(data[j], t)
+				if c < 0 {
+					break
+				}
+				if c == 0 {
+					data[q], data[j] = data[j], data[q]
+					q--
+				}
+			}
+			if i > j {
+				break
+			}
+			data[i], data[j] = data[j], data[i]
+			i++
+			j--
+		}
+
+		// Move the parked equal elements into the middle, which leaves
+		//    [left, lt) < t, [lt, gt] == t, (gt, right] > t
+		lt, gt := left+(i-p), right-(q-j)
+		n := min(p-left, i-p)
+		swapRangeCmpFunc(data, left, i-n, n, cmp)
+		n = min(right-q, q-j)
+		swapRangeCmpFunc(data, j+1, right-n+1, n, cmp)
+
+		switch {
+		case k < lt:
+			right = lt - 1
+		case k > gt:
+			left = gt + 1
+		default:
+			return
+		}
+
+		if right-left > size-size/8 {
+			limit--
+		}
 	}
 }
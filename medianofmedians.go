@@ -0,0 +1,206 @@
+package kth
+
+import (
+	"cmp"
+	"sort"
+)
+
+// MedianOfMedians swaps elements in the data provided so that the first k elements
+// are the smallest k elements in the data, like PDQSelect does, but deterministically
+// and in O(n) worst-case time.
+//
+// It implements the Blum-Floyd-Pratt-Rivest-Tarjan algorithm: the pivot of each
+// partitioning step is the median of the medians of groups of five elements, found
+// recursively, which guarantees that at least 30% of the elements are discarded
+// at every step. Elements equal to the pivot are gathered around it, so that data with
+// many duplicates doesn't weaken that guarantee. It's typically a few times slower
+// than PDQSelect on benign inputs; see PDQSelectWithFallback for a way to only pay
+// for it on adversarial ones.
+//
+// This is plain BFPRT rather than the faster variant of Alexandrescu's "Fast
+// Deterministic Selection", 2017, which takes the pivot from a sample of ninthers
+// gathered in place and adapts the sample to k.
+//
+// Like PDQSelect, it leaves data untouched when k is not within [1, n].
+func MedianOfMedians(data sort.Interface, k int) {
+	n := data.Len()
+	if k < 1 || k > n {
+		return
+	}
+	medianOfMedians(data, 0, n, k-1)
+}
+
+// MedianOfMediansOrdered is a specialized version of MedianOfMedians that works with
+// slices of ordered types (i.e. types that implement the cmp.Ordered interface).
+// Like PDQSelect, it leaves data untouched when k is not within [1, len(data)].
+func MedianOfMediansOrdered[T cmp.Ordered](data []T, k int) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	medianOfMediansOrdered(data, 0, n, k-1)
+}
+
+// MedianOfMediansFunc is a generic version of MedianOfMedians that allows the caller
+// to provide a custom comparison function to determine the order of elements.
+// Like PDQSelect, it leaves data untouched when k is not within [1, len(data)].
+func MedianOfMediansFunc[E any](data []E, k int, less func(a, b E) bool) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	medianOfMediansFunc(data, 0, n, k-1, less)
+}
+
+// medianOfMedians places the element of rank k-a of data[a:b] at index k, with no
+// greater element in data[a:k] and no smaller one in data[k+1:b]. Unlike pdqselect,
+// it doesn't rely on the elements outside of data[a:b].
+func medianOfMedians(data sort.Interface, a, b, k int) {
+	const maxInsertion = 12
+
+	for b-a > maxInsertion {
+		// Gather the medians of groups of five at the front, ignoring the last
+		// group if it's incomplete, and select their median as the pivot.
+		m := a
+		for i := a; i+5 <= b; i += 5 {
+			insertionSort(data, i, i+5)
+			data.Swap(m, i+2)
+			m++
+		}
+		pivot := a + (m-a)/2
+		medianOfMedians(data, a, m, pivot)
+
+		lt, gt := partitionThreeWay(data, a, b, pivot)
+		switch {
+		case k < lt:
+			b = lt
+		case k >= gt:
+			a = gt
+		default:
+			return
+		}
+	}
+
+	insertionSort(data, a, b)
+}
+
+// partitionThreeWay partitions data[a:b] around the element at index pivot, returning
+// the bounds of the elements equal to it, so that data[a:lt] < data[lt:gt] < data[gt:b].
+func partitionThreeWay(data sort.Interface, a, b, pivot int) (lt, gt int) {
+	data.Swap(a, pivot)
+	i := a + 1
+	for j := a + 1; j < b; j++ {
+		if data.Less(j, a) {
+			data.Swap(i, j)
+			i++
+		}
+	}
+
+	lt, gt = i-1, i
+	data.Swap(a, lt)
+	for j := gt; j < b; j++ {
+		if !data.Less(lt, j) {
+			data.Swap(gt, j)
+			gt++
+		}
+	}
+	return lt, gt
+}
+
+func medianOfMediansOrdered[T cmp.Ordered](data []T, a, b, k int) {
+	const maxInsertion = 12
+
+	for b-a > maxInsertion {
+		m := a
+		for i := a; i+5 <= b; i += 5 {
+			insertionSortOrdered(data, i, i+5)
+			data[m], data[i+2] = data[i+2], data[m]
+			m++
+		}
+		pivot := a + (m-a)/2
+		medianOfMediansOrdered(data, a, m, pivot)
+
+		lt, gt := partitionThreeWayOrdered(data, a, b, pivot)
+		switch {
+		case k < lt:
+			b = lt
+		case k >= gt:
+			a = gt
+		default:
+			return
+		}
+	}
+
+	insertionSortOrdered(data, a, b)
+}
+
+func partitionThreeWayOrdered[T cmp.Ordered](data []T, a, b, pivot int) (lt, gt int) {
+	data[a], data[pivot] = data[pivot], data[a]
+	p := data[a]
+	i := a + 1
+	for j := a + 1; j < b; j++ {
+		if cmp.Less(data[j], p) {
+			data[i], data[j] = data[j], data[i]
+			i++
+		}
+	}
+
+	lt, gt = i-1, i
+	data[a], data[lt] = data[lt], data[a]
+	for j := gt; j < b; j++ {
+		if !cmp.Less(p, data[j]) {
+			data[gt], data[j] = data[j], data[gt]
+			gt++
+		}
+	}
+	return lt, gt
+}
+
+func medianOfMediansFunc[E any](data []E, a, b, k int, less func(a, b E) bool) {
+	const maxInsertion = 12
+
+	for b-a > maxInsertion {
+		m := a
+		for i := a; i+5 <= b; i += 5 {
+			insertionSortLessFunc(data, i, i+5, less)
+			data[m], data[i+2] = data[i+2], data[m]
+			m++
+		}
+		pivot := a + (m-a)/2
+		medianOfMediansFunc(data, a, m, pivot, less)
+
+		lt, gt := partitionThreeWayFunc(data, a, b, pivot, less)
+		switch {
+		case k < lt:
+			b = lt
+		case k >= gt:
+			a = gt
+		default:
+			return
+		}
+	}
+
+	insertionSortLessFunc(data, a, b, less)
+}
+
+func partitionThreeWayFunc[E any](data []E, a, b, pivot int, less func(a, b E) bool) (lt, gt int) {
+	data[a], data[pivot] = data[pivot], data[a]
+	p := data[a]
+	i := a + 1
+	for j := a + 1; j < b; j++ {
+		if less(data[j], p) {
+			data[i], data[j] = data[j], data[i]
+			i++
+		}
+	}
+
+	lt, gt = i-1, i
+	data[a], data[lt] = data[lt], data[a]
+	for j := gt; j < b; j++ {
+		if !less(p, data[j]) {
+			data[gt], data[j] = data[j], data[gt]
+			gt++
+		}
+	}
+	return lt, gt
+}
@@ -0,0 +1,103 @@
+package kth
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestMedianOfMedians(t *testing.T) {
+	rng := rand.New(rand.NewPCG(25, 26))
+
+	funcs := []struct {
+		name string
+		fn   func([]int, int)
+	}{
+		{"MedianOfMedians", func(data []int, k int) { MedianOfMedians(sort.IntSlice(data), k) }},
+		{"MedianOfMediansOrdered", MedianOfMediansOrdered[int]},
+		{"MedianOfMediansFunc", func(data []int, k int) { MedianOfMediansFunc(data, k, cmp.Less) }},
+		{"PDQSelectWithFallback", func(data []int, k int) {
+			PDQSelectWithFallback(sort.IntSlice(data), k, MedianOfMediansFallback)
+		}},
+		{"PDQSelectWithFallbackOrdered", func(data []int, k int) {
+			PDQSelectWithFallbackOrdered(data, k, MedianOfMediansFallback)
+		}},
+		{"PDQSelectWithFallbackFunc", func(data []int, k int) {
+			PDQSelectWithFallbackFunc(data, k, MedianOfMediansFallback, cmp.Less)
+		}},
+	}
+
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist, BimodalDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder, PushFrontOrder} {
+			for _, size := range []int{1, 5, 13, 100, 1000} {
+				input := genDistribution(rng, size, dist)
+				applyOrdering(rng, input, order)
+
+				for _, k := range []int{1, 2, size / 2, size - 1, size} {
+					k = min(max(k, 1), size)
+					for _, f := range funcs {
+						name := fmt.Sprintf("%s/n=%d/k=%d/dist=%s/order=%s", f.name, size, k, dist, order)
+						testSelect(t, input, 0, size, k, name, func(data []int, _, _, k int) {
+							f.fn(data, k)
+						})
+					}
+				}
+			}
+		}
+	}
+
+	t.Run("Out of range", func(t *testing.T) {
+		data := []int{3, 1, 2}
+		for _, k := range []int{0, 4} {
+			for _, f := range funcs {
+				f.fn(data, k)
+				if !slices.Equal(data, []int{3, 1, 2}) {
+					t.Fatalf("%s: k=%d: data was modified: %v", f.name, k, data)
+				}
+			}
+		}
+	})
+}
+
+func TestMedianOfMediansAdversary(t *testing.T) {
+	funcs := []struct {
+		name string
+		fn   func(d *adversary, ids []int, k int)
+	}{
+		{"MedianOfMedians", func(d *adversary, ids []int, k int) { MedianOfMedians(adversaryIDs{d, ids}, k) }},
+		{"MedianOfMediansFunc", func(d *adversary, ids []int, k int) { MedianOfMediansFunc(ids, k, d.less) }},
+		{"PDQSelectWithFallback", func(d *adversary, ids []int, k int) {
+			PDQSelectWithFallback(adversaryIDs{d, ids}, k, MedianOfMediansFallback)
+		}},
+		{"PDQSelectWithFallbackFunc", func(d *adversary, ids []int, k int) {
+			PDQSelectWithFallbackFunc(ids, k, MedianOfMediansFallback, d.less)
+		}},
+	}
+
+	// The bound on comparisons must not depend on n for the worst case to be linear.
+	const perElement = 32
+	for _, n := range []int{1000, 16_000, 100_000} {
+		for _, k := range []int{1, n / 4, n / 2, n} {
+			for _, f := range funcs {
+				t.Run(fmt.Sprintf("%s/n=%d/k=%d", f.name, n, k), func(t *testing.T) {
+					d := newAdversary(n)
+					ids := identity(n)
+					f.fn(d, ids, k)
+
+					if bound := perElement * n; d.ncmp > bound {
+						t.Errorf("made %d comparisons, want at most %d", d.ncmp, bound)
+					}
+
+					sorted := slices.Clone(d.values)
+					slices.Sort(sorted)
+					if got := d.values[ids[k-1]]; got != sorted[k-1] {
+						t.Errorf("k-th element = %d, want %d", got, sorted[k-1])
+					}
+				})
+			}
+		}
+	}
+}
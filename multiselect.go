@@ -0,0 +1,372 @@
+package kth
+
+import (
+	"cmp"
+	"math/bits"
+	"slices"
+	"sort"
+)
+
+// PDQSelectMulti swaps elements in the data provided so that, for every k in ks,
+// the k-th smallest element ends up at index k-1 with no larger element before it
+// and no smaller element after it. Ranks outside of [1, n] are ignored, as are
+// duplicates, and ks itself is never modified.
+//
+// All requested order statistics are found in a single recursive pass which only
+// descends into partitions that still contain a requested rank. This is cheaper than
+// calling PDQSelect once per rank, e.g. when computing p50, p90, p99 and p99.9 of the
+// same data.
+func PDQSelectMulti(data sort.Interface, ks []int) {
+	n := data.Len()
+	ranks := multiRanks(ks, n)
+	if len(ranks) == 0 {
+		return
+	}
+	pdqselectMulti(data, 0, n, ranks, bits.Len(uint(n)))
+}
+
+// PDQSelectMultiOrdered is a specialized version of PDQSelectMulti that works with slices of
+// ordered types (i.e. types that implement the cmp.Ordered interface).
+func PDQSelectMultiOrdered[T cmp.Ordered](data []T, ks []int) {
+	n := len(data)
+	ranks := multiRanks(ks, n)
+	if len(ranks) == 0 {
+		return
+	}
+	pdqselectMultiOrdered(data, 0, n, ranks, bits.Len(uint(n)))
+}
+
+// PDQSelectMultiFunc is a generic version of PDQSelectMulti that allows the caller to provide
+// a custom comparison function to determine the order of elements.
+func PDQSelectMultiFunc[E any](data []E, ks []int, less func(a, b E) bool) {
+	n := len(data)
+	ranks := multiRanks(ks, n)
+	if len(ranks) == 0 {
+		return
+	}
+	pdqselectMultiFunc(data, 0, n, ranks, bits.Len(uint(n)), less)
+}
+
+// FloydRivestMulti is the Floyd-Rivest counterpart of PDQSelectMulti. It selects the
+// median requested rank first, using the usual range narrowing, and then only recurses
+// into the two sides of it that still hold requested ranks.
+func FloydRivestMulti(data sort.Interface, ks []int) {
+	n := data.Len()
+	ranks := multiRanks(ks, n)
+	if len(ranks) == 0 {
+		return
+	}
+	floydRivestMulti(data, 0, n-1, ranks)
+}
+
+// FloydRivestMultiOrdered is a specialized version of FloydRivestMulti that works with slices of
+// ordered types (i.e. types that implement the cmp.Ordered interface).
+func FloydRivestMultiOrdered[T cmp.Ordered](data []T, ks []int) {
+	n := len(data)
+	ranks := multiRanks(ks, n)
+	if len(ranks) == 0 {
+		return
+	}
+	floydRivestMultiOrdered(data, 0, n-1, ranks)
+}
+
+// FloydRivestMultiFunc is a generic version of FloydRivestMulti that allows the caller to provide
+// a custom comparison function to determine the order of elements.
+func FloydRivestMultiFunc[E any](data []E, ks []int, less func(a, b E) bool) {
+	n := len(data)
+	ranks := multiRanks(ks, n)
+	if len(ranks) == 0 {
+		return
+	}
+	floydRivestMultiFunc(data, 0, n-1, ranks, less)
+}
+
+// multiRanks converts the 1-based ranks in ks into sorted and de-duplicated
+// 0-based indices, dropping those that fall outside of [1, n].
+func multiRanks(ks []int, n int) []int {
+	ranks := make([]int, 0, len(ks))
+	for _, k := range ks {
+		if k >= 1 && k <= n {
+			ranks = append(ranks, k-1)
+		}
+	}
+	slices.Sort(ranks)
+	return slices.Compact(ranks)
+}
+
+// splitRanks splits the sorted ranks into those before and after mid,
+// dropping mid itself since the partition already placed it.
+func splitRanks(ks []int, mid int) (left, right []int) {
+	i, found := slices.BinarySearch(ks, mid)
+	if found {
+		return ks[:i], ks[i+1:]
+	}
+	return ks[:i], ks[i:]
+}
+
+func pdqselectMulti(data sort.Interface, a, b int, ks []int, limit int) {
+	const maxInsertion = 12
+
+	var (
+		wasBalanced    = true
+		wasPartitioned = true
+	)
+
+	for {
+		switch len(ks) {
+		case 0:
+			return
+		case 1:
+			pdqselect(data, a, b, ks[0], limit)
+			return
+		}
+
+		length := b - a
+
+		if length <= maxInsertion {
+			insertionSort(data, a, b)
+			return
+		}
+
+		// Fall back to heapsort if too many bad choices were made.
+		if limit == 0 {
+			heapSort(data, a, b)
+			return
+		}
+
+		// Break patterns if the last partitioning was imbalanced
+		if !wasBalanced {
+			breakPatterns(data, a, b)
+			limit--
+		}
+
+		pivot, hint := choosePivot(data, a, b)
+		if hint == decreasingHint {
+			reverseRange(data, a, b)
+			pivot = (b - 1) - (pivot - a)
+			hint = increasingHint
+		}
+
+		// Check if the slice is likely already sorted
+		if wasBalanced && wasPartitioned && hint == increasingHint {
+			if partialInsertionSort(data, a, b) {
+				return
+			}
+		}
+
+		// Probably the slice contains many duplicate elements. Every requested rank
+		// that falls into the run of elements equal to the pivot is already done.
+		if a > 0 && !data.Less(a-1, pivot) {
+			mid := partitionEqual(data, a, b, pivot)
+			i, _ := slices.BinarySearch(ks, mid)
+			a, ks = mid, ks[i:]
+			continue
+		}
+
+		mid, alreadyPartitioned := partition(data, a, b, pivot)
+		wasPartitioned = alreadyPartitioned
+
+		left, right := splitRanks(ks, mid)
+		leftLen, rightLen := mid-a, b-mid
+		balanceThreshold := length / 8
+
+		// Recurse into the shorter side to bound the stack depth and loop on the other.
+		if leftLen < rightLen {
+			wasBalanced = leftLen >= balanceThreshold
+			pdqselectMulti(data, a, mid, left, limit)
+			a, ks = mid+1, right
+		} else {
+			wasBalanced = rightLen >= balanceThreshold
+			pdqselectMulti(data, mid+1, b, right, limit)
+			b, ks = mid, left
+		}
+	}
+}
+
+func pdqselectMultiOrdered[T cmp.Ordered](data []T, a, b int, ks []int, limit int) {
+	const maxInsertion = 12
+
+	var (
+		wasBalanced    = true
+		wasPartitioned = true
+	)
+
+	for {
+		switch len(ks) {
+		case 0:
+			return
+		case 1:
+			pdqselectOrdered(data, a, b, ks[0], limit)
+			return
+		}
+
+		length := b - a
+
+		if length <= maxInsertion {
+			insertionSortOrdered(data, a, b)
+			return
+		}
+
+		// Fall back to heapsort if too many bad choices were made.
+		if limit == 0 {
+			heapSortOrdered(data, a, b)
+			return
+		}
+
+		// Break patterns if the last partitioning was imbalanced
+		if !wasBalanced {
+			breakPatternsOrdered(data, a, b)
+			limit--
+		}
+
+		pivot, hint := choosePivotOrdered(data, a, b)
+		if hint == decreasingHint {
+			reverseRangeOrdered(data, a, b)
+			pivot = (b - 1) - (pivot - a)
+			hint = increasingHint
+		}
+
+		// Check if the slice is likely already sorted
+		if wasBalanced && wasPartitioned && hint == increasingHint {
+			if partialInsertionSortOrdered(data, a, b) {
+				return
+			}
+		}
+
+		// Probably the slice contains many duplicate elements. Every requested rank
+		// that falls into the run of elements equal to the pivot is already done.
+		if a > 0 && data[a-1] >= data[pivot] {
+			mid := partitionEqualOrdered(data, a, b, pivot)
+			i, _ := slices.BinarySearch(ks, mid)
+			a, ks = mid, ks[i:]
+			continue
+		}
+
+		mid, alreadyPartitioned := partitionOrdered(data, a, b, pivot)
+		wasPartitioned = alreadyPartitioned
+
+		left, right := splitRanks(ks, mid)
+		leftLen, rightLen := mid-a, b-mid
+		balanceThreshold := length / 8
+
+		// Recurse into the shorter side to bound the stack depth and loop on the other.
+		if leftLen < rightLen {
+			wasBalanced = leftLen >= balanceThreshold
+			pdqselectMultiOrdered(data, a, mid, left, limit)
+			a, ks = mid+1, right
+		} else {
+			wasBalanced = rightLen >= balanceThreshold
+			pdqselectMultiOrdered(data, mid+1, b, right, limit)
+			b, ks = mid, left
+		}
+	}
+}
+
+func pdqselectMultiFunc[E any](data []E, a, b int, ks []int, limit int, less func(a, b E) bool) {
+	const maxInsertion = 12
+
+	var (
+		wasBalanced    = true
+		wasPartitioned = true
+	)
+
+	for {
+		switch len(ks) {
+		case 0:
+			return
+		case 1:
+			pdqselectFunc(data, a, b, ks[0], limit, less)
+			return
+		}
+
+		length := b - a
+
+		if length <= maxInsertion {
+			insertionSortLessFunc(data, a, b, less)
+			return
+		}
+
+		// Fall back to heapsort if too many bad choices were made.
+		if limit == 0 {
+			heapSortLessFunc(data, a, b, less)
+			return
+		}
+
+		// Break patterns if the last partitioning was imbalanced
+		if !wasBalanced {
+			breakPatternsLessFunc(data, a, b)
+			limit--
+		}
+
+		pivot, hint := choosePivotLessFunc(data, a, b, less)
+		if hint == decreasingHint {
+			reverseRangeLessFunc(data, a, b)
+			pivot = (b - 1) - (pivot - a)
+			hint = increasingHint
+		}
+
+		// Check if the slice is likely already sorted
+		if wasBalanced && wasPartitioned && hint == increasingHint {
+			if partialInsertionSortLessFunc(data, a, b, less) {
+				return
+			}
+		}
+
+		// Probably the slice contains many duplicate elements. Every requested rank
+		// that falls into the run of elements equal to the pivot is already done.
+		if a > 0 && !less(data[a-1], data[pivot]) {
+			mid := partitionEqualLessFunc(data, a, b, pivot, less)
+			i, _ := slices.BinarySearch(ks, mid)
+			a, ks = mid, ks[i:]
+			continue
+		}
+
+		mid, alreadyPartitioned := partitionLessFunc(data, a, b, pivot, less)
+		wasPartitioned = alreadyPartitioned
+
+		left, right := splitRanks(ks, mid)
+		leftLen, rightLen := mid-a, b-mid
+		balanceThreshold := length / 8
+
+		// Recurse into the shorter side to bound the stack depth and loop on the other.
+		if leftLen < rightLen {
+			wasBalanced = leftLen >= balanceThreshold
+			pdqselectMultiFunc(data, a, mid, left, limit, less)
+			a, ks = mid+1, right
+		} else {
+			wasBalanced = rightLen >= balanceThreshold
+			pdqselectMultiFunc(data, mid+1, b, right, limit, less)
+			b, ks = mid, left
+		}
+	}
+}
+
+func floydRivestMulti(data sort.Interface, left, right int, ks []int) {
+	for len(ks) > 0 {
+		m := len(ks) / 2
+		k := ks[m]
+		floydRivest(data, left, right, k, bits.Len(uint(right-left+1)))
+		floydRivestMulti(data, left, k-1, ks[:m])
+		left, ks = k+1, ks[m+1:]
+	}
+}
+
+func floydRivestMultiOrdered[T cmp.Ordered](data []T, left, right int, ks []int) {
+	for len(ks) > 0 {
+		m := len(ks) / 2
+		k := ks[m]
+		floydRivestOrdered(data, left, right, k, bits.Len(uint(right-left+1)))
+		floydRivestMultiOrdered(data, left, k-1, ks[:m])
+		left, ks = k+1, ks[m+1:]
+	}
+}
+
+func floydRivestMultiFunc[E any](data []E, left, right int, ks []int, less func(a, b E) bool) {
+	for len(ks) > 0 {
+		m := len(ks) / 2
+		k := ks[m]
+		floydRivestFunc(data, left, right, k, bits.Len(uint(right-left+1)), less)
+		floydRivestMultiFunc(data, left, k-1, ks[:m], less)
+		left, ks = k+1, ks[m+1:]
+	}
+}
@@ -0,0 +1,109 @@
+package kth
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestSelectMulti(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	type multiCase struct {
+		name  string
+		input []int
+		ks    []int
+	}
+
+	cases := []multiCase{
+		{"Empty", []int{}, []int{1}},
+		{"No ranks", []int{3, 1, 2}, nil},
+		{"Out of range", []int{3, 1, 2}, []int{0, 4, -1}},
+		{"Single", []int{42}, []int{1}},
+		{"Duplicated ranks", []int{5, 4, 3, 2, 1}, []int{2, 2, 4, 2}},
+		{"Unsorted ranks", []int{3, 7, 2, 1, 4, 6, 5, 8, 9}, []int{9, 1, 5}},
+		{"All equal", []int{1, 1, 1, 1, 1}, []int{1, 3, 5}},
+	}
+
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist, BimodalDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder, MostlySorted} {
+			for _, size := range []int{13, 100, 5000} {
+				data := genDistribution(rng, size, dist)
+				applyOrdering(rng, data, order)
+				ks := []int{1, size / 2, size * 9 / 10, size * 99 / 100, size}
+				for range 5 {
+					ks = append(ks, 1+rng.IntN(size))
+				}
+				name := fmt.Sprintf("n=%d/dist=%s/order=%s", size, dist, order)
+				cases = append(cases, multiCase{name, data, ks})
+			}
+		}
+	}
+
+	funcs := []struct {
+		name string
+		fn   func([]int, []int)
+	}{
+		{"PDQSelectMulti", func(data, ks []int) { PDQSelectMulti(sort.IntSlice(data), ks) }},
+		{"PDQSelectMultiOrdered", func(data, ks []int) { PDQSelectMultiOrdered(data, ks) }},
+		{"PDQSelectMultiFunc", func(data, ks []int) { PDQSelectMultiFunc(data, ks, cmp.Less) }},
+		{"FloydRivestMulti", func(data, ks []int) { FloydRivestMulti(sort.IntSlice(data), ks) }},
+		{"FloydRivestMultiOrdered", func(data, ks []int) { FloydRivestMultiOrdered(data, ks) }},
+		{"FloydRivestMultiFunc", func(data, ks []int) { FloydRivestMultiFunc(data, ks, cmp.Less) }},
+		{"pdqselectMultiOrdered/limit=0", func(data, ks []int) {
+			if ranks := multiRanks(ks, len(data)); len(ranks) > 0 {
+				pdqselectMultiOrdered(data, 0, len(data), ranks, 0)
+			}
+		}},
+	}
+
+	for _, tc := range cases {
+		for _, f := range funcs {
+			t.Run(f.name+"/"+tc.name, func(t *testing.T) {
+				testSelectMulti(t, tc.input, tc.ks, f.fn)
+			})
+		}
+	}
+}
+
+func testSelectMulti(t *testing.T, input, ks []int, selectFunc func([]int, []int)) {
+	t.Helper()
+
+	sorted := slices.Clone(input)
+	slices.Sort(sorted)
+
+	output := slices.Clone(input)
+	ksCopy := slices.Clone(ks)
+	selectFunc(output, ksCopy)
+
+	if !slices.Equal(ks, ksCopy) {
+		t.Fatalf("ranks were modified: got %v, want %v", ksCopy, ks)
+	}
+
+	got := slices.Clone(output)
+	slices.Sort(got)
+	if !slices.Equal(got, sorted) {
+		t.Fatalf("output is not a permutation of the input")
+	}
+
+	for _, k := range multiRanks(ks, len(input)) {
+		if output[k] != sorted[k] {
+			t.Errorf("k=%d: element (%d) does not match sorted input (%d)", k+1, output[k], sorted[k])
+		}
+		for i := 0; i < k; i++ {
+			if output[i] > output[k] {
+				t.Errorf("k=%d: element at index %d (%d) is larger than k-th element (%d)", k+1, i, output[i], output[k])
+				break
+			}
+		}
+		for i := k + 1; i < len(output); i++ {
+			if output[i] < output[k] {
+				t.Errorf("k=%d: element at index %d (%d) is smaller than k-th element (%d)", k+1, i, output[i], output[k])
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package kth
+
+import (
+	"cmp"
+	"math/bits"
+	"runtime"
+	"sync"
+)
+
+const (
+	// parallelThreshold is the size below which parallel selection hands over to
+	// pdqselect, as partitioning concurrently doesn't pay off anymore.
+	parallelThreshold = 1 << 16
+
+	// parallelSampleSize is the number of elements sampled in each round of parallel
+	// selection to choose a pair of pivots bracketing the k-th element.
+	parallelSampleSize = 4096
+)
+
+// ParallelSelectOrdered is a parallel version of PDQSelectOrdered meant for very large
+// slices, where selection is bound by memory bandwidth rather than by comparisons.
+//
+// It proceeds in rounds. Each round sorts a random sample of the data to choose two
+// pivots that likely bracket the k-th smallest element, partitions the data around them
+// with up to workers goroutines working on separate chunks, and carries on with the part
+// holding rank k, which is typically a sixteenth of the size. Once that part is below
+// 65536 elements, or if a round doesn't at least halve it, pdqselect takes over.
+// If workers < 1, runtime.GOMAXPROCS(0) workers are used.
+//
+// On return, data satisfies the same guarantee as after PDQSelectOrdered: the first
+// k elements are the smallest k elements, with the k-th smallest at index k-1.
+// Like PDQSelect, it leaves data untouched when k is not within [1, len(data)].
+//
+// There's no sort.Interface variant, since implementations of Swap aren't generally
+// safe to call concurrently, even on disjoint indices.
+func ParallelSelectOrdered[T cmp.Ordered](data []T, k, workers int) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	parallelSelectOrdered(data, k-1, workers)
+}
+
+// ParallelSelectFunc is a generic version of ParallelSelectOrdered that allows the caller
+// to provide a custom comparison function to determine the order of elements.
+// less is called concurrently from multiple goroutines, so it must be safe to do so.
+func ParallelSelectFunc[E any](data []E, k, workers int, less func(a, b E) bool) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	parallelSelectFunc(data, k-1, workers, less)
+}
+
+func parallelSelectOrdered[T cmp.Ordered](data []T, k, workers int) {
+	a, b := 0, len(data)
+	rng := xorshift(len(data))
+	var sample []T
+
+	for workers > 1 && b-a >= parallelThreshold {
+		n := b - a
+		if sample == nil {
+			sample = make([]T, parallelSampleSize)
+		}
+		for i := range sample {
+			sample[i] = data[a+int(rng.Next()%uint64(n))]
+		}
+		pdqsortOrdered(sample, 0, len(sample), bits.Len(uint(len(sample))))
+		lo, hi := pivotRanks(k-a, n)
+		p, q := sample[lo], sample[hi]
+
+		// Split data[a:b] into elements < p, elements within [p, q] and elements > q,
+		// keeping only the part that holds k. Everything left of a is then no larger
+		// than anything within data[a:b], which is what pdqselect requires.
+		m := parallelPartition(data[a:b], workers, func(chunk []T) int {
+			return splitLessOrdered(chunk, p)
+		})
+		if k < a+m {
+			b = a + m
+		} else {
+			a += m
+			m = parallelPartition(data[a:b], workers, func(chunk []T) int {
+				return splitLessOrEqualOrdered(chunk, q)
+			})
+			if k >= a+m {
+				a += m
+			} else if b = a + m; !cmp.Less(p, q) {
+				return // All elements of data[a:b] are equal, so k is in place.
+			}
+		}
+
+		if b-a > n/2 {
+			break
+		}
+	}
+
+	pdqselectOrdered(data, a, b, k, bits.Len(uint(b-a)))
+}
+
+func parallelSelectFunc[E any](data []E, k, workers int, less func(a, b E) bool) {
+	a, b := 0, len(data)
+	rng := xorshift(len(data))
+	var sample []E
+
+	for workers > 1 && b-a >= parallelThreshold {
+		n := b - a
+		if sample == nil {
+			sample = make([]E, parallelSampleSize)
+		}
+		for i := range sample {
+			sample[i] = data[a+int(rng.Next()%uint64(n))]
+		}
+		pdqsortLessFunc(sample, 0, len(sample), bits.Len(uint(len(sample))), less)
+		lo, hi := pivotRanks(k-a, n)
+		p, q := sample[lo], sample[hi]
+
+		// Split data[a:b] into elements < p, elements within [p, q] and elements > q,
+		// keeping only the part that holds k. Everything left of a is then no larger
+		// than anything within data[a:b], which is what pdqselect requires.
+		m := parallelPartition(data[a:b], workers, func(chunk []E) int {
+			return splitLessFunc(chunk, p, less)
+		})
+		if k < a+m {
+			b = a + m
+		} else {
+			a += m
+			m = parallelPartition(data[a:b], workers, func(chunk []E) int {
+				return splitLessOrEqualFunc(chunk, q, less)
+			})
+			if k >= a+m {
+				a += m
+			} else if b = a + m; !less(p, q) {
+				return // All elements of data[a:b] are equal, so k is in place.
+			}
+		}
+
+		if b-a > n/2 {
+			break
+		}
+	}
+
+	pdqselectFunc(data, a, b, k, bits.Len(uint(b-a)), less)
+}
+
+// pivotRanks returns the ranks within a sorted sample of two pivots that bracket the
+// element of rank k out of n with high probability, i.e. unless the sample is off
+// by more than four standard deviations.
+func pivotRanks(k, n int) (lo, hi int) {
+	const delta = 128 // 2*sqrt(parallelSampleSize)
+	r := k * parallelSampleSize / n
+	return max(r-delta, 0), min(r+delta, parallelSampleSize-1)
+}
+
+// span is a range [lo, hi) of indices.
+type span struct{ lo, hi int }
+
+// parallelPartition splits data into contiguous chunks that are partitioned concurrently
+// by up to workers goroutines, calling partition on each of them. partition must move the
+// elements of the chunk that belong to the left part to its front and return their count.
+// Elements of the right part that end up left of the boundary between both parts are then
+// swapped concurrently with elements of the left part that end up right of it.
+// parallelPartition returns the number of elements in the left part.
+func parallelPartition[E any](data []E, workers int, partition func([]E) int) int {
+	n := len(data)
+	size := (n + workers - 1) / workers
+	counts := make([]int, (n+size-1)/size)
+
+	var wg sync.WaitGroup
+	for i := range counts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counts[i] = partition(data[i*size : min((i+1)*size, n)])
+		}()
+	}
+	wg.Wait()
+
+	m := 0
+	for _, c := range counts {
+		m += c
+	}
+
+	// strayRight holds the spans of right elements within data[:m], and strayLeft
+	// the spans of left elements within data[m:]. Both have the same total length.
+	var strayRight, strayLeft []span
+	stray := 0
+	for i, c := range counts {
+		lo, mid, hi := i*size, i*size+c, min((i+1)*size, n)
+		if s := (span{mid, min(hi, m)}); s.lo < s.hi {
+			strayRight = append(strayRight, s)
+			stray += s.hi - s.lo
+		}
+		if s := (span{max(lo, m), mid}); s.lo < s.hi {
+			strayLeft = append(strayLeft, s)
+		}
+	}
+
+	// Hand out at least 4096 swaps per goroutine.
+	parts := min(workers, 1+stray>>12)
+	for p := 0; p < parts; p++ {
+		from, to := p*stray/parts, (p+1)*stray/parts
+		if from == to {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			swapSpans(data, strayRight, strayLeft, from, to)
+		}()
+	}
+	wg.Wait()
+
+	return m
+}
+
+// swapSpans swaps the elements at positions [from, to) of the concatenation of the spans
+// in x with the elements at the same positions of the concatenation of the spans in y.
+func swapSpans[E any](data []E, x, y []span, from, to int) {
+	i, u := seekSpan(x, from)
+	j, v := seekSpan(y, from)
+	for n := to - from; ; {
+		run := min(n, x[i].hi-u, y[j].hi-v)
+		for r := 0; r < run; r++ {
+			data[u+r], data[v+r] = data[v+r], data[u+r]
+		}
+		if n -= run; n == 0 {
+			return
+		}
+		if u += run; u == x[i].hi {
+			i++
+			u = x[i].lo
+		}
+		if v += run; v == y[j].hi {
+			j++
+			v = y[j].lo
+		}
+	}
+}
+
+// seekSpan returns the index of the span holding position pos of the concatenation
+// of spans, along with the corresponding index into data.
+func seekSpan(spans []span, pos int) (i, index int) {
+	for pos >= spans[i].hi-spans[i].lo {
+		pos -= spans[i].hi - spans[i].lo
+		i++
+	}
+	return i, spans[i].lo + pos
+}
+
+// splitLessOrdered moves the elements of data that are less than pivot to its
+// front, returning their count.
+func splitLessOrdered[T cmp.Ordered](data []T, pivot T) int {
+	i, j := 0, len(data)-1
+	for {
+		for i <= j && cmp.Less(data[i], pivot) {
+			i++
+		}
+		for i <= j && !cmp.Less(data[j], pivot) {
+			j--
+		}
+		if i >= j {
+			return i
+		}
+		data[i], data[j] = data[j], data[i]
+		i++
+		j--
+	}
+}
+
+// splitLessOrEqualOrdered moves the elements of data that are less than or equal
+// to pivot to its front, returning their count.
+func splitLessOrEqualOrdered[T cmp.Ordered](data []T, pivot T) int {
+	i, j := 0, len(data)-1
+	for {
+		for i <= j && !cmp.Less(pivot, data[i]) {
+			i++
+		}
+		for i <= j && cmp.Less(pivot, data[j]) {
+			j--
+		}
+		if i >= j {
+			return i
+		}
+		data[i], data[j] = data[j], data[i]
+		i++
+		j--
+	}
+}
+
+func splitLessFunc[E any](data []E, pivot E, less func(a, b E) bool) int {
+	i, j := 0, len(data)-1
+	for {
+		for i <= j && less(data[i], pivot) {
+			i++
+		}
+		for i <= j && !less(data[j], pivot) {
+			j--
+		}
+		if i >= j {
+			return i
+		}
+		data[i], data[j] = data[j], data[i]
+		i++
+		j--
+	}
+}
+
+func splitLessOrEqualFunc[E any](data []E, pivot E, less func(a, b E) bool) int {
+	i, j := 0, len(data)-1
+	for {
+		for i <= j && !less(pivot, data[i]) {
+			i++
+		}
+		for i <= j && less(pivot, data[j]) {
+			j--
+		}
+		if i >= j {
+			return i
+		}
+		data[i], data[j] = data[j], data[i]
+		i++
+		j--
+	}
+}
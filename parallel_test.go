@@ -0,0 +1,92 @@
+package kth
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestParallelSelect(t *testing.T) {
+	rng := rand.New(rand.NewPCG(23, 24))
+
+	funcs := []struct {
+		name string
+		fn   func(data []int, k, workers int)
+	}{
+		{"ParallelSelectOrdered", ParallelSelectOrdered[int]},
+		{"ParallelSelectFunc", func(data []int, k, workers int) { ParallelSelectFunc(data, k, workers, cmp.Less) }},
+	}
+
+	// Sizes above parallelThreshold exercise the concurrent partitioning rounds,
+	// which are best run with the race detector enabled.
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist, BimodalDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder} {
+			for _, size := range []int{1, 1000, parallelThreshold, 200_003} {
+				input := genDistribution(rng, size, dist)
+				applyOrdering(rng, input, order)
+				sorted := slices.Clone(input)
+				slices.Sort(sorted)
+
+				for _, k := range []int{1, size / 3, size / 2, size} {
+					k = max(k, 1)
+					for _, workers := range []int{0, 1, 3, 8} {
+						for _, f := range funcs {
+							name := fmt.Sprintf("%s/n=%d/k=%d/workers=%d/dist=%s/order=%s", f.name, size, k, workers, dist, order)
+							t.Run(name, func(t *testing.T) {
+								data := slices.Clone(input)
+								f.fn(data, k, workers)
+								checkSelected(t, data, sorted, k)
+							})
+						}
+					}
+				}
+			}
+		}
+	}
+
+	t.Run("Two values", func(t *testing.T) {
+		// With two distinct values and k in between, both pivots are bound to be
+		// the two values, so partitioning makes no progress.
+		input := make([]int, 200_000)
+		for i := range input {
+			input[i] = i % 2
+		}
+		sorted := slices.Clone(input)
+		slices.Sort(sorted)
+		for _, k := range []int{1, 100_000, 100_001, 200_000} {
+			data := slices.Clone(input)
+			ParallelSelectOrdered(data, k, 4)
+			checkSelected(t, data, sorted, k)
+		}
+	})
+
+	t.Run("Out of range", func(t *testing.T) {
+		data := []int{3, 1, 2}
+		for _, k := range []int{0, 4} {
+			ParallelSelectOrdered(data, k, 4)
+			ParallelSelectFunc(data, k, 4, cmp.Less)
+			if data[0] != 3 || data[1] != 1 || data[2] != 2 {
+				t.Fatalf("k=%d: data was modified: %v", k, data)
+			}
+		}
+	})
+}
+
+// checkSelected reports the first element of data that isn't on the correct side of
+// its k-th element, given a sorted copy of data, without dumping large slices.
+func checkSelected(t *testing.T, data, sorted []int, k int) {
+	t.Helper()
+	if data[k-1] != sorted[k-1] {
+		t.Fatalf("k-th element = %d, want %d", data[k-1], sorted[k-1])
+	}
+	for i, x := range data {
+		if i < k && x > sorted[k-1] || i >= k && x < sorted[k-1] {
+			t.Fatalf("element %d at index %d is on the wrong side of the k-th element %d", x, i, sorted[k-1])
+		}
+	}
+	if slices.Sort(data); !slices.Equal(data, sorted) {
+		t.Fatalf("data is not a permutation of the input")
+	}
+}
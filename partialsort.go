@@ -0,0 +1,282 @@
+package kth
+
+import (
+	"cmp"
+	"math/bits"
+	"sort"
+)
+
+// PartialSort swaps elements in the data provided so that the first k elements
+// are the smallest k elements in the data, in sorted order. No particular order
+// is guaranteed among the remaining elements.
+//
+// Selection and sorting are fused into a single pass: every partition that lies
+// entirely within the first k elements is sorted as soon as it is produced, while
+// partitions beyond k are discarded just like in PDQSelect. Partitions that are
+// found to be already sorted or that only contain duplicates are never re-sorted.
+func PartialSort(data sort.Interface, k int) {
+	n := data.Len()
+	if k < 1 || k > n {
+		return
+	}
+	partialSort(data, 0, n, k, bits.Len(uint(n)))
+}
+
+// PartialSortOrdered is a specialized version of PartialSort that works with slices of
+// ordered types (i.e. types that implement the cmp.Ordered interface).
+func PartialSortOrdered[T cmp.Ordered](data []T, k int) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	partialSortOrdered(data, 0, n, k, bits.Len(uint(n)))
+}
+
+// PartialSortFunc is a generic version of PartialSort that allows the caller to provide
+// a custom comparison function to determine the order of elements.
+func PartialSortFunc[E any](data []E, k int, less func(a, b E) bool) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	partialSortFunc(data, 0, n, k, bits.Len(uint(n)), less)
+}
+
+// FloydRivestPartialSort is like PartialSort, but it uses FloydRivest to select the
+// k-th element before sorting the k-1 elements in front of it with pdqsort.
+func FloydRivestPartialSort(data sort.Interface, k int) {
+	n := data.Len()
+	if k < 1 || k > n {
+		return
+	}
+	floydRivest(data, 0, n-1, k-1, bits.Len(uint(n)))
+	pdqsort(data, 0, k-1, bits.Len(uint(k-1)))
+}
+
+// FloydRivestPartialSortOrdered is a specialized version of FloydRivestPartialSort that works
+// with slices of ordered types (i.e. types that implement the cmp.Ordered interface).
+func FloydRivestPartialSortOrdered[T cmp.Ordered](data []T, k int) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	floydRivestOrdered(data, 0, n-1, k-1, bits.Len(uint(n)))
+	pdqsortOrdered(data, 0, k-1, bits.Len(uint(k-1)))
+}
+
+// FloydRivestPartialSortFunc is a generic version of FloydRivestPartialSort that allows the
+// caller to provide a custom comparison function to determine the order of elements.
+func FloydRivestPartialSortFunc[E any](data []E, k int, less func(a, b E) bool) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	floydRivestFunc(data, 0, n-1, k-1, bits.Len(uint(n)), less)
+	pdqsortLessFunc(data, 0, k-1, bits.Len(uint(k-1)), less)
+}
+
+// partialSort sorts the data[a:k] prefix of data[a:b] such that it holds the
+// smallest k-a elements of data[a:b].
+func partialSort(data sort.Interface, a, b, k, limit int) {
+	const maxInsertion = 12
+
+	var (
+		wasBalanced    = true
+		wasPartitioned = true
+	)
+
+	for a < k {
+		length := b - a
+
+		if length <= maxInsertion {
+			insertionSort(data, a, b)
+			return
+		}
+
+		// Fall back to heap select and heapsort if too many bad choices were made.
+		if limit == 0 {
+			heapSelect(data, a, b, k-1-a)
+			heapSort(data, a, k-1)
+			return
+		}
+
+		// Break patterns if the last partitioning was imbalanced
+		if !wasBalanced {
+			breakPatterns(data, a, b)
+			limit--
+		}
+
+		pivot, hint := choosePivot(data, a, b)
+		if hint == decreasingHint {
+			reverseRange(data, a, b)
+			pivot = (b - 1) - (pivot - a)
+			hint = increasingHint
+		}
+
+		// Check if the slice is likely already sorted
+		if wasBalanced && wasPartitioned && hint == increasingHint {
+			if partialInsertionSort(data, a, b) {
+				return
+			}
+		}
+
+		// Probably the slice contains many duplicate elements. The run of elements
+		// equal to the pivot is sorted by definition, so skip past it.
+		if a > 0 && !data.Less(a-1, pivot) {
+			a = partitionEqual(data, a, b, pivot)
+			continue
+		}
+
+		mid, alreadyPartitioned := partition(data, a, b, pivot)
+		wasPartitioned = alreadyPartitioned
+		leftLen, rightLen := mid-a, b-mid
+		balanceThreshold := length / 8
+
+		if k <= mid {
+			wasBalanced = leftLen >= balanceThreshold
+			b = mid
+			continue
+		}
+
+		// The whole left side belongs to the smallest k elements.
+		wasBalanced = rightLen >= balanceThreshold
+		pdqsort(data, a, mid, limit)
+		a = mid + 1
+	}
+}
+
+func partialSortOrdered[T cmp.Ordered](data []T, a, b, k, limit int) {
+	const maxInsertion = 12
+
+	var (
+		wasBalanced    = true
+		wasPartitioned = true
+	)
+
+	for a < k {
+		length := b - a
+
+		if length <= maxInsertion {
+			insertionSortOrdered(data, a, b)
+			return
+		}
+
+		// Fall back to heap select and heapsort if too many bad choices were made.
+		if limit == 0 {
+			heapSelectOrdered(data, a, b, k-1-a)
+			heapSortOrdered(data, a, k-1)
+			return
+		}
+
+		// Break patterns if the last partitioning was imbalanced
+		if !wasBalanced {
+			breakPatternsOrdered(data, a, b)
+			limit--
+		}
+
+		pivot, hint := choosePivotOrdered(data, a, b)
+		if hint == decreasingHint {
+			reverseRangeOrdered(data, a, b)
+			pivot = (b - 1) - (pivot - a)
+			hint = increasingHint
+		}
+
+		// Check if the slice is likely already sorted
+		if wasBalanced && wasPartitioned && hint == increasingHint {
+			if partialInsertionSortOrdered(data, a, b) {
+				return
+			}
+		}
+
+		// Probably the slice contains many duplicate elements. The run of elements
+		// equal to the pivot is sorted by definition, so skip past it.
+		if a > 0 && data[a-1] >= data[pivot] {
+			a = partitionEqualOrdered(data, a, b, pivot)
+			continue
+		}
+
+		mid, alreadyPartitioned := partitionOrdered(data, a, b, pivot)
+		wasPartitioned = alreadyPartitioned
+		leftLen, rightLen := mid-a, b-mid
+		balanceThreshold := length / 8
+
+		if k <= mid {
+			wasBalanced = leftLen >= balanceThreshold
+			b = mid
+			continue
+		}
+
+		// The whole left side belongs to the smallest k elements.
+		wasBalanced = rightLen >= balanceThreshold
+		pdqsortOrdered(data, a, mid, limit)
+		a = mid + 1
+	}
+}
+
+func partialSortFunc[E any](data []E, a, b, k, limit int, less func(a, b E) bool) {
+	const maxInsertion = 12
+
+	var (
+		wasBalanced    = true
+		wasPartitioned = true
+	)
+
+	for a < k {
+		length := b - a
+
+		if length <= maxInsertion {
+			insertionSortLessFunc(data, a, b, less)
+			return
+		}
+
+		// Fall back to heap select and heapsort if too many bad choices were made.
+		if limit == 0 {
+			heapSelectFunc(data, a, b, k-1-a, less)
+			heapSortLessFunc(data, a, k-1, less)
+			return
+		}
+
+		// Break patterns if the last partitioning was imbalanced
+		if !wasBalanced {
+			breakPatternsLessFunc(data, a, b)
+			limit--
+		}
+
+		pivot, hint := choosePivotLessFunc(data, a, b, less)
+		if hint == decreasingHint {
+			reverseRangeLessFunc(data, a, b)
+			pivot = (b - 1) - (pivot - a)
+			hint = increasingHint
+		}
+
+		// Check if the slice is likely already sorted
+		if wasBalanced && wasPartitioned && hint == increasingHint {
+			if partialInsertionSortLessFunc(data, a, b, less) {
+				return
+			}
+		}
+
+		// Probably the slice contains many duplicate elements. The run of elements
+		// equal to the pivot is sorted by definition, so skip past it.
+		if a > 0 && !less(data[a-1], data[pivot]) {
+			a = partitionEqualLessFunc(data, a, b, pivot, less)
+			continue
+		}
+
+		mid, alreadyPartitioned := partitionLessFunc(data, a, b, pivot, less)
+		wasPartitioned = alreadyPartitioned
+		leftLen, rightLen := mid-a, b-mid
+		balanceThreshold := length / 8
+
+		if k <= mid {
+			wasBalanced = leftLen >= balanceThreshold
+			b = mid
+			continue
+		}
+
+		// The whole left side belongs to the smallest k elements.
+		wasBalanced = rightLen >= balanceThreshold
+		pdqsortLessFunc(data, a, mid, limit, less)
+		a = mid + 1
+	}
+}
@@ -0,0 +1,87 @@
+package kth
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestPartialSort(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+
+	type partialSortCase struct {
+		name  string
+		input []int
+		k     int
+	}
+
+	cases := []partialSortCase{
+		{"Single element", []int{42}, 1},
+		{"Two elements", []int{2, 1}, 1},
+		{"Full sort", []int{3, 7, 2, 1, 4, 6, 5, 8, 9}, 9},
+		{"All equal", []int{1, 1, 1, 1, 1}, 3},
+		{"Mostly equal", []int{2, 2, 2, 2, 1, 2, 2, 3, 2, 2}, 6},
+	}
+
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist, BimodalDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder, MostlySorted, PushFrontOrder} {
+			for _, size := range []int{13, 100, 5000} {
+				data := genDistribution(rng, size, dist)
+				applyOrdering(rng, data, order)
+				for _, k := range []int{1, 2, size / 10, size / 2, size - 1, size} {
+					name := fmt.Sprintf("n=%d/k=%d/dist=%s/order=%s", size, k, dist, order)
+					cases = append(cases, partialSortCase{name, data, k})
+				}
+			}
+		}
+	}
+
+	funcs := []struct {
+		name string
+		fn   func([]int, int)
+	}{
+		{"PartialSort", func(data []int, k int) { PartialSort(sort.IntSlice(data), k) }},
+		{"PartialSortOrdered", func(data []int, k int) { PartialSortOrdered(data, k) }},
+		{"PartialSortFunc", func(data []int, k int) { PartialSortFunc(data, k, cmp.Less) }},
+		{"FloydRivestPartialSort", func(data []int, k int) { FloydRivestPartialSort(sort.IntSlice(data), k) }},
+		{"FloydRivestPartialSortOrdered", func(data []int, k int) { FloydRivestPartialSortOrdered(data, k) }},
+		{"FloydRivestPartialSortFunc", func(data []int, k int) { FloydRivestPartialSortFunc(data, k, cmp.Less) }},
+		{"partialSortOrdered/limit=0", func(data []int, k int) { partialSortOrdered(data, 0, len(data), k, 0) }},
+	}
+
+	for _, tc := range cases {
+		for _, f := range funcs {
+			t.Run(f.name+"/"+tc.name, func(t *testing.T) {
+				sorted := slices.Clone(tc.input)
+				slices.Sort(sorted)
+
+				output := slices.Clone(tc.input)
+				f.fn(output, tc.k)
+
+				if !slices.Equal(output[:tc.k], sorted[:tc.k]) {
+					t.Fatalf("prefix is not the sorted k smallest elements\nwant: %v\ngot:  %v", sorted[:tc.k], output[:tc.k])
+				}
+
+				rest := slices.Clone(output[tc.k:])
+				slices.Sort(rest)
+				if !slices.Equal(rest, sorted[tc.k:]) {
+					t.Fatalf("suffix is not a permutation of the remaining elements")
+				}
+			})
+		}
+	}
+
+	t.Run("Out of range", func(t *testing.T) {
+		for _, k := range []int{-1, 0, 4} {
+			data := []int{3, 1, 2}
+			PartialSortOrdered(data, k)
+			FloydRivestPartialSortOrdered(data, k)
+			if !slices.Equal(data, []int{3, 1, 2}) {
+				t.Errorf("k=%d: data was modified: %v", k, data)
+			}
+		}
+	})
+}
@@ -17,6 +17,10 @@ import (
 //
 // It's an adaptation of Go's internal pdqsort implementation, which makes it adaptive
 // to bad data patterns like already sorted data, duplicate elements, and more.
+//
+// k must be within [1, n], with k == n placing the largest element at index n-1.
+// Otherwise, which is always the case for empty data, PDQSelect returns without
+// touching data. Use PDQSelectChecked to get an error instead.
 func PDQSelect(data sort.Interface, k int) {
 	n := data.Len()
 	if k < 1 || k > n {
@@ -27,6 +31,7 @@ func PDQSelect(data sort.Interface, k int) {
 
 // PDQSelectOrdered is a specialized version of Select that works with slices of
 // ordered types (i.e. types that implement the cmp.Ordered interface).
+// Like PDQSelect, it leaves data untouched when k is not within [1, len(data)].
 func PDQSelectOrdered[T cmp.Ordered](data []T, k int) {
 	n := len(data)
 	if k < 1 || k > n {
@@ -37,6 +42,7 @@ func PDQSelectOrdered[T cmp.Ordered](data []T, k int) {
 
 // PDQSelectFunc is a generic version of Select that allows the caller to provide
 // a custom comparison function to determine the order of elements.
+// Like PDQSelect, it leaves data untouched when k is not within [1, len(data)].
 func PDQSelectFunc[E any](data []E, k int, less func(i, j E) bool) {
 	n := len(data)
 	if k < 1 || k > n {
@@ -45,7 +51,177 @@ func PDQSelectFunc[E any](data []E, k int, less func(i, j E) bool) {
 	pdqselectFunc(data, 0, n, k-1, bits.Len(uint(n)), less)
 }
 
+// PDQSelectCmpFunc is like PDQSelectFunc, but takes a three-way comparison function
+// as used by slices.SortFunc and slices.BinarySearchFunc, returning a negative number
+// when a < b, a positive number when a > b and zero when a == b.
+// Like PDQSelect, it leaves data untouched when k is not within [1, len(data)].
+//
+// Each partitioning step routes every element below, equal to or above the pivot with
+// a single call, and stops as soon as the k-th element falls among the equal ones,
+// which saves calls on data with many duplicates, and matters when comparisons are
+// expensive. Moving the equal elements costs extra swaps though, so on mostly distinct
+// data with a cheap comparison, such as cmp.Compare on integers, PDQSelectCmpFunc is
+// up to about 25% slower than PDQSelectFunc, which should be preferred there.
+func PDQSelectCmpFunc[E any](data []E, k int, cmp func(a, b E) int) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	pdqselectCmpFunc(data, 0, n, k-1, bits.Len(uint(n)), cmp)
+}
+
+// PDQSelectWithin is like PDQSelect, but only considers the elements in the window
+// data[a:b], leaving everything outside of it untouched. This is useful for types
+// that can't cheaply be re-sliced, such as struct-of-arrays layouts.
+//
+// k is global: it's a 1-based position within data rather than within the window,
+// so the element that would be at index k-1 if data[a:b] was sorted ends up there,
+// with no greater element in data[a:k-1] and no smaller one in data[k:b]. This makes
+// PDQSelectWithin(data, 0, n, k) equivalent to PDQSelect(data, k).
+//
+// The window must satisfy 0 <= a < b <= n and k must be within [a+1, b]. Otherwise,
+// PDQSelectWithin returns without touching data.
+func PDQSelectWithin(data sort.Interface, a, b, k int) {
+	if a < 0 || b > data.Len() || k <= a || k > b {
+		return
+	}
+	if a > 0 {
+		// pdqselect relies on data[a-1] being no greater than any element in data[a:b]
+		// whenever a > 0, which doesn't hold for an arbitrary window. Placing the minimum
+		// of the window at its start establishes it for the rest of the window.
+		mn := a
+		for i := a + 1; i < b; i++ {
+			if data.Less(i, mn) {
+				mn = i
+			}
+		}
+		if mn != a {
+			data.Swap(mn, a)
+		}
+		if a++; a == k {
+			return
+		}
+	}
+	pdqselect(data, a, b, k-1, bits.Len(uint(b-a)))
+}
+
+// PDQSelectWithinOrdered is a specialized version of PDQSelectWithin that works with slices of
+// ordered types (i.e. types that implement the cmp.Ordered interface).
+func PDQSelectWithinOrdered[T cmp.Ordered](data []T, a, b, k int) {
+	if a < 0 || b > len(data) || k <= a || k > b {
+		return
+	}
+	if a > 0 {
+		// See PDQSelectWithin.
+		mn := a
+		for i := a + 1; i < b; i++ {
+			if data[i] < data[mn] {
+				mn = i
+			}
+		}
+		data[a], data[mn] = data[mn], data[a]
+		if a++; a == k {
+			return
+		}
+	}
+	pdqselectOrdered(data, a, b, k-1, bits.Len(uint(b-a)))
+}
+
+// PDQSelectWithinFunc is a generic version of PDQSelectWithin that allows the caller to provide
+// a custom comparison function to determine the order of elements.
+func PDQSelectWithinFunc[E any](data []E, a, b, k int, less func(a, b E) bool) {
+	if a < 0 || b > len(data) || k <= a || k > b {
+		return
+	}
+	if a > 0 {
+		// See PDQSelectWithin.
+		mn := a
+		for i := a + 1; i < b; i++ {
+			if less(data[i], data[mn]) {
+				mn = i
+			}
+		}
+		if mn != a {
+			data[a], data[mn] = data[mn], data[a]
+		}
+		if a++; a == k {
+			return
+		}
+	}
+	pdqselectFunc(data, a, b, k-1, bits.Len(uint(b-a)), less)
+}
+
+// Fallback is the algorithm that PDQSelectWithFallback switches to when too many of
+// its pivot choices turned out to be bad, which only happens on adversarial inputs.
+type Fallback int
+
+const (
+	// HeapSelectFallback selects with a binary heap, as PDQSelect does. It bounds the
+	// worst case of the whole selection to O(n log n).
+	HeapSelectFallback Fallback = iota
+
+	// MedianOfMediansFallback selects with MedianOfMedians. Combined with a constant
+	// budget of bad pivot choices, it bounds the worst case of the whole selection to
+	// O(n) at the cost of a slightly higher chance of falling back on benign inputs.
+	//
+	// That bound only holds if every partition that isn't counted as a bad choice
+	// discards a constant fraction of the range, so with this fallback a pivot close to
+	// either end counts as one, as in pdqsort, even when the smaller side is kept.
+	MedianOfMediansFallback
+)
+
+// medianOfMediansLimit is the number of bad pivot choices tolerated before falling back
+// to median of medians. Each costs up to O(n), while the remaining partitions shrink the
+// range geometrically, so any constant keeps the total linear.
+const medianOfMediansLimit = 4
+
+// limit returns the number of bad pivot choices pdqselect tolerates on n elements
+// before switching to f.
+func (f Fallback) limit(n int) int {
+	if f == MedianOfMediansFallback {
+		return min(bits.Len(uint(n)), medianOfMediansLimit)
+	}
+	return bits.Len(uint(n))
+}
+
+// PDQSelectWithFallback is like PDQSelect, but lets the caller choose the algorithm to
+// fall back to after too many bad pivot choices. PDQSelectWithFallback(data, k,
+// HeapSelectFallback) is equivalent to PDQSelect(data, k), while MedianOfMediansFallback
+// guarantees O(n) worst-case running time.
+// Like PDQSelect, it leaves data untouched when k is not within [1, n].
+func PDQSelectWithFallback(data sort.Interface, k int, fallback Fallback) {
+	n := data.Len()
+	if k < 1 || k > n {
+		return
+	}
+	pdqselectFallback(data, 0, n, k-1, fallback.limit(n), fallback)
+}
+
+// PDQSelectWithFallbackOrdered is a specialized version of PDQSelectWithFallback that
+// works with slices of ordered types (i.e. types that implement the cmp.Ordered interface).
+func PDQSelectWithFallbackOrdered[T cmp.Ordered](data []T, k int, fallback Fallback) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	pdqselectFallbackOrdered(data, 0, n, k-1, fallback.limit(n), fallback)
+}
+
+// PDQSelectWithFallbackFunc is a generic version of PDQSelectWithFallback that allows the
+// caller to provide a custom comparison function to determine the order of elements.
+func PDQSelectWithFallbackFunc[E any](data []E, k int, fallback Fallback, less func(a, b E) bool) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	pdqselectFallbackFunc(data, 0, n, k-1, fallback.limit(n), fallback, less)
+}
+
 func pdqselect(data sort.Interface, a, b, k, limit int) {
+	pdqselectFallback(data, a, b, k, limit, HeapSelectFallback)
+}
+
+func pdqselectFallback(data sort.Interface, a, b, k, limit int, fallback Fallback) {
 	if k == 0 { // Fast path; just find the minimum and place it in a
 		mn := a
 		for i := a + 1; i < b; i++ {
@@ -87,9 +263,14 @@ func pdqselect(data sort.Interface, a, b, k, limit int) {
 			return
 		}
 
-		// Fall back to heap select if too many bad choices were made.
+		// Fall back to heap select, or median of medians if asked to, if too many
+		// bad choices were made.
 		if limit == 0 {
-			heapSelect(data, a, b, k)
+			if fallback == MedianOfMediansFallback {
+				medianOfMedians(data, a, b, k)
+			} else {
+				heapSelect(data, a, b, k-a)
+			}
 			return
 		}
 
@@ -143,10 +324,19 @@ func pdqselect(data sort.Interface, a, b, k, limit int) {
 			wasBalanced = rightLen >= balanceThreshold
 			a = mid + 1
 		}
+
+		// See MedianOfMediansFallback.
+		if fallback == MedianOfMediansFallback {
+			wasBalanced = min(leftLen, rightLen) >= balanceThreshold
+		}
 	}
 }
 
 func pdqselectOrdered[T cmp.Ordered](data []T, a, b, k, limit int) {
+	pdqselectFallbackOrdered(data, a, b, k, limit, HeapSelectFallback)
+}
+
+func pdqselectFallbackOrdered[T cmp.Ordered](data []T, a, b, k, limit int, fallback Fallback) {
 	if k == 0 { // Fast path; just find the minimum and place it in a
 		mn := a
 		for i := a + 1; i < b; i++ {
@@ -184,9 +374,14 @@ func pdqselectOrdered[T cmp.Ordered](data []T, a, b, k, limit int) {
 			return
 		}
 
-		// Fall back to heap select if too many bad choices were made.
+		// Fall back to heap select, or median of medians if asked to, if too many
+		// bad choices were made.
 		if limit == 0 {
-			heapSelectOrdered(data, a, b, k)
+			if fallback == MedianOfMediansFallback {
+				medianOfMediansOrdered(data, a, b, k)
+			} else {
+				heapSelectOrdered(data, a, b, k-a)
+			}
 			return
 		}
 
@@ -224,7 +419,15 @@ func pdqselectOrdered[T cmp.Ordered](data []T, a, b, k, limit int) {
 			continue
 		}
 
-		mid, alreadyPartitioned := partitionOrdered(data, a, b, pivot)
+		var (
+			mid                int
+			alreadyPartitioned bool
+		)
+		if useBlockPartition[T](length) {
+			mid, alreadyPartitioned = partitionBlockOrdered(data, a, b, pivot)
+		} else {
+			mid, alreadyPartitioned = partitionOrdered(data, a, b, pivot)
+		}
 		if k == mid {
 			return
 		}
@@ -240,10 +443,19 @@ func pdqselectOrdered[T cmp.Ordered](data []T, a, b, k, limit int) {
 			wasBalanced = rightLen >= balanceThreshold
 			a = mid + 1
 		}
+
+		// See MedianOfMediansFallback.
+		if fallback == MedianOfMediansFallback {
+			wasBalanced = min(leftLen, rightLen) >= balanceThreshold
+		}
 	}
 }
 
 func pdqselectFunc[E any](data []E, a, b, k, limit int, less func(a, b E) bool) {
+	pdqselectFallbackFunc(data, a, b, k, limit, HeapSelectFallback, less)
+}
+
+func pdqselectFallbackFunc[E any](data []E, a, b, k, limit int, fallback Fallback, less func(a, b E) bool) {
 	if k == 0 { // Fast path; just find the minimum and place it in a
 		mn := a
 		for i := a + 1; i < b; i++ {
@@ -285,9 +497,14 @@ func pdqselectFunc[E any](data []E, a, b, k, limit int, less func(a, b E) bool)
 			return
 		}
 
-		// Fall back to heap select if too many bad choices were made.
+		// Fall back to heap select, or median of medians if asked to, if too many
+		// bad choices were made.
 		if limit == 0 {
-			heapSelectFunc(data, a, b, k, less)
+			if fallback == MedianOfMediansFallback {
+				medianOfMediansFunc(data, a, b, k, less)
+			} else {
+				heapSelectFunc(data, a, b, k-a, less)
+			}
 			return
 		}
 
@@ -341,9 +558,162 @@ func pdqselectFunc[E any](data []E, a, b, k, limit int, less func(a, b E) bool)
 			wasBalanced = rightLen >= balanceThreshold
 			a = mid + 1
 		}
+
+		// See MedianOfMediansFallback.
+		if fallback == MedianOfMediansFallback {
+			wasBalanced = min(leftLen, rightLen) >= balanceThreshold
+		}
 	}
 }
 
+func pdqselectCmpFunc[E any](data []E, a, b, k, limit int, cmp func(a, b E) int) {
+	if k == 0 { // Fast path; just find the minimum and place it in a
+		mn := a
+		for i := a + 1; i < b; i++ {
+			if cmp(data[i], data[mn]) < 0 {
+				mn = i
+			}
+		}
+		if mn != a {
+			data[a], data[mn] = data[mn], data[a]
+		}
+		return
+	}
+
+	if hi := b - 1; k == hi { // Fast path; just find the maximum
+		mx := a
+		for i := a + 1; i < b; i++ {
+			if cmp(data[mx], data[i]) < 0 {
+				mx = i
+			}
+		}
+		if mx != hi {
+			data[hi], data[mx] = data[mx], data[hi]
+		}
+		return
+	}
+
+	const maxInsertion = 12
+
+	var (
+		wasBalanced    = true
+		wasPartitioned = true
+	)
+
+	for {
+		length := b - a
+
+		if length <= maxInsertion {
+			insertionSortCmpFunc(data, a, b, cmp)
+			return
+		}
+
+		// Fall back to heap select if too many bad choices were made.
+		if limit == 0 {
+			heapSelectCmpFunc(data, a, b, k-a, cmp)
+			return
+		}
+
+		// Break patterns if the last partitioning was imbalanced
+		if !wasBalanced {
+			breakPatternsCmpFunc(data, a, b, cmp)
+			limit--
+		}
+
+		pivot, hint := choosePivotCmpFunc(data, a, b, cmp)
+		if hint == decreasingHint {
+			reverseRangeCmpFunc(data, a, b, cmp)
+			// The chosen pivot was pivot-a elements after the start of the array.
+			// After reversing it is pivot-a elements before the end of the array.
+			// The idea came from Rust's implementation.
+			pivot = (b - 1) - (pivot - a)
+			hint = increasingHint
+		}
+
+		// Check if the slice is likely already sorted
+		if wasBalanced && wasPartitioned && hint == increasingHint {
+			if partialInsertionSortCmpFunc(data, a, b, cmp) {
+				return
+			}
+		}
+
+		// Partition into elements smaller than, equal to and greater than the pivot,
+		// which takes a single three-way comparison per element, and stop as soon as the
+		// k-th element falls among the equal ones. This makes duplicates of the pivot
+		// drop out right away, without the separate pass PDQSelectFunc needs for them.
+		lt, gt, alreadyPartitioned := partitionThreeWayCmpFunc(data, a, b, pivot, cmp)
+		if k >= lt && k < gt {
+			return
+		}
+
+		wasPartitioned = alreadyPartitioned
+		balanceThreshold := length / 8
+
+		if k < lt {
+			wasBalanced = lt-a >= balanceThreshold
+			b = lt
+		} else {
+			wasBalanced = b-gt >= balanceThreshold
+			a = gt
+		}
+	}
+}
+
+// partitionThreeWayCmpFunc partitions data[a:b] around data[pivot] with one call to cmp
+// per element, save for the one where the scans cross, so that data[a:lt] are smaller
+// than the pivot, data[lt:gt] equal to it and data[gt:b] greater. It reports whether no
+// element had to cross sides.
+func partitionThreeWayCmpFunc[E any](data []E, a, b, pivot int, cmp func(a, b E) int) (lt, gt int, alreadyPartitioned bool) {
+	data[a], data[pivot] = data[pivot], data[a]
+	t := data[a]
+
+	// Bentley-McIlroy partitioning, which parks elements equal to the pivot at both ends
+	// while scanning, the pivot itself included:
+	//    [a, p) == t, [p, i) < t, (j, q] > t, (q, b) == t
+	i, j := a+1, b-1
+	p, q := a+1, b-1
+	alreadyPartitioned = true
+	for {
+		for ; i <= j; i++ {
+			c := cmp(data[i], t)
+			if c > 0 {
+				break
+			}
+			if c == 0 {
+				data[p], data[i] = data[i], data[p]
+				p++
+			}
+		}
+		for ; i <= j; j-- {
+			c := cmp(data[j], t)
+			if c < 0 {
+				break
+			}
+			if c == 0 {
+				data[q], data[j] = data[j], data[q]
+				q--
+			}
+		}
+		if i > j {
+			break
+		}
+		data[i], data[j] = data[j], data[i]
+		alreadyPartitioned = false
+		i++
+		j--
+	}
+
+	// Move the parked elements into the middle.
+	lt, gt = a+(i-p), b-(q-j)
+	n := min(p-a, i-p)
+	swapRangeCmpFunc(data, a, i-n, n, cmp)
+	n = min(b-1-q, q-j)
+	swapRangeCmpFunc(data, j+1, b-n, n, cmp)
+	return lt, gt, alreadyPartitioned
+}
+
+// heapSelect places the k-th smallest element of data[a:b] at index a+k, with k
+// being relative to a, and the k elements smaller than it in data[a:a+k].
 func heapSelect(data sort.Interface, a, b, k int) {
 	n := b - a
 	hi := k + 1
@@ -409,3 +779,25 @@ func heapSelectFunc[E any](data []E, a, b, k int, less func(a, b E) bool) {
 	// Place the k-th element into its final place
 	data[a], data[a+k] = data[a+k], data[a]
 }
+
+func heapSelectCmpFunc[E any](data []E, a, b, k int, cmp func(a, b E) int) {
+	n := b - a
+	hi := k + 1
+
+	// Build max-heap of first k elements
+	for i := k / 2; i >= 0; i-- {
+		siftDownCmpFunc(data, i, hi, a, cmp)
+	}
+
+	// Process remaining elements
+	for i := hi; i < n; i++ {
+		j := a + i
+		if cmp(data[j], data[a]) < 0 {
+			data[a], data[j] = data[j], data[a]
+			siftDownCmpFunc(data, 0, hi, a, cmp)
+		}
+	}
+
+	// Place the k-th element into its final place
+	data[a], data[a+k] = data[a+k], data[a]
+}
@@ -0,0 +1,193 @@
+package kth
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// Number is a constraint that permits any integer or floating-point type.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// QuantileMethod selects one of the nine sample quantile definitions described by
+// Hyndman and Fan in "Sample Quantiles in Statistical Packages", The American
+// Statistician, 50(4), 1996. The value of each method matches its type number in R.
+//
+// The zero value selects Linear, which is the default in both R and NumPy.
+type QuantileMethod int
+
+const (
+	// InvertedCDF is the inverse of the empirical distribution function (R type 1).
+	InvertedCDF QuantileMethod = iota + 1
+	// AveragedInvertedCDF is like InvertedCDF, but averages at discontinuities (R type 2).
+	AveragedInvertedCDF
+	// ClosestObservation picks the nearest even order statistic (R type 3, SAS definition 2).
+	ClosestObservation
+	// InterpolatedInvertedCDF linearly interpolates the empirical distribution function (R type 4).
+	InterpolatedInvertedCDF
+	// Hazen is the piecewise linear function whose knots are the midpoints of
+	// the steps of the empirical distribution function (R type 5).
+	Hazen
+	// Weibull uses p(k) = k / (n + 1), as Minitab and SPSS do (R type 6).
+	Weibull
+	// Linear uses p(k) = (k - 1) / (n - 1), as R, NumPy and Excel do by default (R type 7).
+	Linear
+	// MedianUnbiased makes the quantiles approximately median-unbiased
+	// regardless of the distribution of the data (R type 8).
+	MedianUnbiased
+	// NormalUnbiased makes the quantiles approximately unbiased
+	// if the data is normally distributed (R type 9).
+	NormalUnbiased
+)
+
+// quantileFuzz absorbs floating-point error when computing the order statistic
+// a quantile falls on, just like R's quantile.default does.
+const quantileFuzz = 4 * 2.220446049250313e-16
+
+// Quantile returns the q-th quantile of data, for q in [0, 1], computed according to the
+// given method. It returns NaN if data is empty or q is out of range.
+//
+// Quantile reorders data in place. It performs a single selection for the lower order
+// statistic and, when interpolation is required, finds the upper one by scanning for the
+// minimum of the partition to its right, so no sorting takes place.
+func Quantile[T Number](data []T, q float64, method QuantileMethod) float64 {
+	n := len(data)
+	if n == 0 || !(q >= 0 && q <= 1) {
+		return math.NaN()
+	}
+
+	lo, hi, h := method.index(n, q)
+	pdqselectOrdered(data, 0, n, lo, bits.Len(uint(n)))
+
+	x := float64(data[lo])
+	if h == 0 || hi == lo {
+		return x
+	}
+	return interpolate(x, float64(slices.Min(data[lo+1:])), h)
+}
+
+// Quantiles returns the quantiles of data for each q in qs, computed according to the given
+// method. Entries of the result for which q is out of [0, 1] are NaN, as are all entries if
+// data is empty.
+//
+// Quantiles reorders data in place, placing all order statistics it needs with a single call
+// to PDQSelectMultiOrdered.
+func Quantiles[T Number](data []T, qs []float64, method QuantileMethod) []float64 {
+	n := len(data)
+	out := make([]float64, len(qs))
+
+	ranks := make([]int, 0, 2*len(qs))
+	for _, q := range qs {
+		if n == 0 || !(q >= 0 && q <= 1) {
+			continue
+		}
+		lo, hi, h := method.index(n, q)
+		ranks = append(ranks, lo)
+		if h != 0 && hi != lo {
+			ranks = append(ranks, hi)
+		}
+	}
+
+	if len(ranks) > 0 {
+		slices.Sort(ranks)
+		pdqselectMultiOrdered(data, 0, n, slices.Compact(ranks), bits.Len(uint(n)))
+	}
+
+	for i, q := range qs {
+		if n == 0 || !(q >= 0 && q <= 1) {
+			out[i] = math.NaN()
+			continue
+		}
+		lo, hi, h := method.index(n, q)
+		if h == 0 || hi == lo {
+			out[i] = float64(data[lo])
+		} else {
+			out[i] = interpolate(float64(data[lo]), float64(data[hi]), h)
+		}
+	}
+
+	return out
+}
+
+// Median returns the median of data, averaging the two middle elements when
+// len(data) is even. It returns NaN if data is empty. Median reorders data in place.
+func Median[T Number](data []T) float64 {
+	return Quantile(data, 0.5, Linear)
+}
+
+// index returns the 0-based indices of the two order statistics of n elements that the
+// p-th quantile lies between, as well as the weight h given to the upper one.
+func (m QuantileMethod) index(n int, p float64) (lo, hi int, h float64) {
+	nf := float64(n)
+
+	var nppm float64
+	switch m {
+	case InvertedCDF, AveragedInvertedCDF:
+		nppm = nf * p
+	case ClosestObservation:
+		nppm = nf*p - 0.5
+	default:
+		a, b := m.params()
+		nppm = a + p*(nf+1-a-b)
+	}
+
+	j := math.Floor(nppm + quantileFuzz)
+
+	switch m {
+	case InvertedCDF:
+		if nppm > j {
+			h = 1
+		}
+	case AveragedInvertedCDF:
+		if nppm > j {
+			h = 1
+		} else {
+			h = 0.5
+		}
+	case ClosestObservation:
+		if nppm != j || int(j)&1 == 1 {
+			h = 1
+		}
+	default:
+		if h = nppm - j; math.Abs(h) < quantileFuzz {
+			h = 0
+		}
+	}
+
+	// j is a 1-based order statistic, which may fall just outside of [1, n].
+	lo = min(max(int(j)-1, 0), n-1)
+	hi = min(max(int(j), 0), n-1)
+	return lo, hi, h
+}
+
+// params returns the plotting position parameters α and β of the continuous methods.
+func (m QuantileMethod) params() (a, b float64) {
+	switch m {
+	case InterpolatedInvertedCDF:
+		return 0, 1
+	case Hazen:
+		return 0.5, 0.5
+	case Weibull:
+		return 0, 0
+	case 0, Linear:
+		return 1, 1
+	case MedianUnbiased:
+		return 1.0 / 3, 1.0 / 3
+	case NormalUnbiased:
+		return 3.0 / 8, 3.0 / 8
+	default:
+		panic("kth: invalid quantile method")
+	}
+}
+
+// interpolate returns the point a fraction h of the way from x to y.
+func interpolate(x, y, h float64) float64 {
+	if h == 1 {
+		return y
+	}
+	return x + h*(y-x)
+}
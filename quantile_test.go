@@ -0,0 +1,154 @@
+package kth
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestQuantile(t *testing.T) {
+	testCases := []struct {
+		method QuantileMethod
+		q      float64
+		want   float64
+	}{
+		{InvertedCDF, 0.25, 1},
+		{AveragedInvertedCDF, 0.25, 1.5},
+		{ClosestObservation, 0.25, 1},
+		{InterpolatedInvertedCDF, 0.25, 1},
+		{Hazen, 0.25, 1.5},
+		{Weibull, 0.25, 1.25},
+		{Linear, 0.25, 1.75},
+		{MedianUnbiased, 0.25, 1 + 5.0/12},
+		{NormalUnbiased, 0.25, 1.4375},
+		{0, 0.25, 1.75},
+		{InvertedCDF, 0.5, 2},
+		{AveragedInvertedCDF, 0.5, 2.5},
+		{ClosestObservation, 0.5, 2},
+		{InterpolatedInvertedCDF, 0.5, 2},
+		{Hazen, 0.5, 2.5},
+		{Weibull, 0.5, 2.5},
+		{Linear, 0.5, 2.5},
+		{MedianUnbiased, 0.5, 2.5},
+		{NormalUnbiased, 0.5, 2.5},
+		{Linear, 0, 1},
+		{Linear, 1, 4},
+		{Weibull, 0, 1},
+		{Weibull, 1, 4},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("method=%d/q=%v", tc.method, tc.q), func(t *testing.T) {
+			data := []int{4, 2, 1, 3}
+			if got := Quantile(data, tc.q, tc.method); math.Abs(got-tc.want) > 1e-12 {
+				t.Errorf("Quantile() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Edge cases", func(t *testing.T) {
+		if got := Quantile([]float64{}, 0.5, Linear); !math.IsNaN(got) {
+			t.Errorf("empty data: got %v, want NaN", got)
+		}
+		for _, q := range []float64{-0.1, 1.1, math.NaN()} {
+			if got := Quantile([]float64{1, 2}, q, Linear); !math.IsNaN(got) {
+				t.Errorf("q=%v: got %v, want NaN", q, got)
+			}
+		}
+		if got := Median([]uint8{7}); got != 7 {
+			t.Errorf("single element median = %v, want 7", got)
+		}
+		if got := Median([]int{5, 1, 4, 2, 3, 6}); got != 3.5 {
+			t.Errorf("even length median = %v, want 3.5", got)
+		}
+	})
+}
+
+func TestQuantileMethods(t *testing.T) {
+	rng := rand.New(rand.NewPCG(5, 6))
+
+	for _, size := range []int{1, 2, 3, 10, 101, 1000} {
+		for _, dist := range []Distribution{UniformDist, ZipfDist, BimodalDist} {
+			data := genDistribution(rng, size, dist)
+			sorted := slices.Clone(data)
+			slices.Sort(sorted)
+
+			qs := []float64{0, 0.5, 0.9, 0.99, 1}
+			for range 20 {
+				qs = append(qs, rng.Float64())
+			}
+
+			for method := InvertedCDF; method <= NormalUnbiased; method++ {
+				name := fmt.Sprintf("n=%d/dist=%s/method=%d", size, dist, method)
+				t.Run(name, func(t *testing.T) {
+					got := Quantiles(slices.Clone(data), qs, method)
+					for i, q := range qs {
+						want := referenceQuantile(sorted, q, method)
+						if !closeEnough(got[i], want) {
+							t.Errorf("Quantiles(q=%v) = %v, want %v", q, got[i], want)
+						}
+						if single := Quantile(slices.Clone(data), q, method); !closeEnough(single, want) {
+							t.Errorf("Quantile(q=%v) = %v, want %v", q, single, want)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// referenceQuantile implements the Hyndman-Fan definitions on sorted data in terms
+// of their m offsets and γ functions, as laid out in the paper.
+func referenceQuantile(sorted []int, p float64, method QuantileMethod) float64 {
+	n := float64(len(sorted))
+	x := func(j int) float64 { // 1-based with clamping
+		return float64(sorted[min(max(j, 1), len(sorted))-1])
+	}
+
+	var m float64
+	switch method {
+	case ClosestObservation:
+		m = -0.5
+	case Hazen:
+		m = 0.5
+	case Weibull:
+		m = p
+	case Linear:
+		m = 1 - p
+	case MedianUnbiased:
+		m = (p + 1) / 3
+	case NormalUnbiased:
+		m = p/4 + 3.0/8
+	}
+
+	jf := math.Floor(n*p + m)
+	j, g := int(jf), n*p+m-jf
+
+	var gamma float64
+	switch method {
+	case InvertedCDF:
+		if g > 0 {
+			gamma = 1
+		}
+	case AveragedInvertedCDF:
+		if g > 0 {
+			gamma = 1
+		} else {
+			gamma = 0.5
+		}
+	case ClosestObservation:
+		if g > 0 || j%2 != 0 {
+			gamma = 1
+		}
+	default:
+		gamma = g
+	}
+
+	return (1-gamma)*x(j) + gamma*x(j+1)
+}
+
+func closeEnough(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*max(1, math.Abs(a), math.Abs(b))
+}
@@ -0,0 +1,202 @@
+package kth
+
+import (
+	"math"
+	"math/bits"
+	"unsafe"
+)
+
+const (
+	// radixThreshold is the size below which radix selection hands over to pdqselect,
+	// as histograms of 256 buckets don't pay off anymore.
+	radixThreshold = 256
+
+	// countingThreshold is the minimum ratio of the number of elements to the number
+	// of possible values for which RadixSelect counts the occurrences of every value
+	// of 8-bit and 16-bit types rather than selecting byte by byte.
+	countingThreshold = 1
+
+	// radixBits is the width of the digits radix selection builds histograms of.
+	radixBits = 8
+	radixMask = 1<<radixBits - 1
+)
+
+// RadixSelect swaps elements in the data provided so that the first k elements are the
+// smallest k elements in the data, like PDQSelectOrdered, without comparing elements to
+// each other.
+//
+// It maps each element to an unsigned integer key that sorts in the same order, counts
+// the keys by their most significant byte that isn't the same for all of them, and
+// carries on with the next byte among the keys of the bucket holding rank k, moving them
+// between the smaller and larger ones once they're few enough. When fewer than 256
+// elements are left, pdqselect takes over. That's O(n) time for any input, with a
+// constant that depends on the width of T and on how many elements share the bytes
+// of the k-th smallest one.
+//
+// Integers of 8 and 16 bits are counted and written back in sorted order instead, when
+// data holds at least as many elements as T has values. For 16-bit integers, that takes
+// a table of 65536 counts, which is the only memory RadixSelect allocates.
+//
+// Floats are ordered by IEEE 754's totalOrder predicate: -0 sorts before +0, and NaNs
+// sort before -Inf or after +Inf depending on their sign bit, which is clear for the
+// NaNs returned by math.NaN. See SelectFloat for other ways of handling NaNs.
+//
+// RadixSelect is usually 2 to 4 times faster than PDQSelectOrdered on large slices of
+// 8-bit and 16-bit integers, and on par with it for wider types whose values are spread
+// out. It's slower on small slices, and on wider types with many duplicates, which it
+// scans once per byte before they can be told apart.
+//
+// Like PDQSelect, it leaves data untouched when k is not within [1, len(data)].
+func RadixSelect[T Number](data []T, k int) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+
+	r := newRadix[T]()
+	if !r.float && r.width <= 16 && n>>r.width >= countingThreshold {
+		countingSelect(data, r)
+		return
+	}
+	radixSelect(data, k-1, r)
+}
+
+// radix describes how the elements of a Number type map to the keys RadixSelect uses.
+type radix struct {
+	float bool
+	flip  uint64 // xor-ed into the bits of integers, to order negative ones first
+	shift int    // applied to integers, to place their bits in the high end of keys
+	width int    // of T, in bits
+}
+
+func newRadix[T Number]() radix {
+	var zero T
+	half := 0.5
+	r := radix{float: T(half) != 0, width: int(unsafe.Sizeof(zero)) * 8}
+	if zero-1 < 0 {
+		r.flip = 1 << (r.width - 1)
+	}
+	r.shift = 64 - r.width
+	return r
+}
+
+// radixKey maps x to an unsigned integer that sorts like x does. Floats of either width
+// are converted to float64 first, which preserves their order.
+func radixKey[T Number](x T, r radix) uint64 {
+	if r.float {
+		b := math.Float64bits(float64(x))
+		return b ^ (uint64(int64(b)>>63) | 1<<63)
+	}
+	return (uint64(x) ^ r.flip) << r.shift
+}
+
+// radixSelect places the element of rank k of data at index k, with no greater element
+// in data[:k] and no smaller one in data[k+1:].
+func radixSelect[T Number](data []T, k int, r radix) {
+	// The candidates for rank k are the elements of data[a:b] whose keys shifted right
+	// by shift equal prefix, and they belong in data[lo:hi]. Partitioning moves them
+	// there, but only pays off once they are few enough, so data[a:b] can be scanned a
+	// few times before; with heavy duplicates, most of it often stays a candidate.
+	a, b := 0, len(data)
+	lo, hi := a, b
+	shift, prefix := 64, uint64(0)
+
+	for hi-lo > radixThreshold {
+		// Skip the leading bits that all candidates share.
+		or, and := uint64(0), ^uint64(0)
+		for _, x := range data[a:b] {
+			key := radixKey(x, r)
+			m := radixMatch(key>>shift, prefix)
+			or |= key & -m
+			and &= key | (m - 1)
+		}
+		width := bits.Len64(or ^ and)
+		if width == 0 && lo == a && hi == b {
+			return // All keys within data[a:b] are equal.
+		}
+		shift = max(width-radixBits, 0)
+
+		var counts [1<<radixBits + 1]int // The last bucket counts non-candidates.
+		for _, x := range data[a:b] {
+			key := radixKey(x, r)
+			m := radixMatch(key>>width, and>>width)
+			counts[key>>shift&radixMask&-m|(1-m)<<radixBits]++
+		}
+
+		digit := 0
+		for lo+counts[digit] <= k {
+			lo += counts[digit]
+			digit++
+		}
+		hi = lo + counts[digit]
+		prefix = and>>shift&^radixMask | uint64(digit)
+
+		if shift == 0 || hi-lo <= radixThreshold || hi-lo <= (b-a)/2 {
+			if lo > a || hi < b {
+				radixPartition(data[a:b], prefix, shift, lo-a, r)
+			}
+			a, b = lo, hi
+			if shift == 0 {
+				return // All keys within data[a:b] are equal.
+			}
+		}
+	}
+	if lo > a || hi < b {
+		radixPartition(data[a:b], prefix, shift, lo-a, r)
+		a, b = lo, hi
+	}
+
+	if r.float {
+		data = data[a:b]
+		pdqselectFunc(data, 0, len(data), k-a, bits.Len(uint(len(data))), func(x, y T) bool {
+			return radixKey(x, r) < radixKey(y, r)
+		})
+		return
+	}
+	// Everything left of a is smaller than anything within data[a:b], which is what
+	// pdqselect requires, and < orders integers like their keys.
+	pdqselectOrdered(data, a, b, k, bits.Len(uint(b-a)))
+}
+
+// radixMatch returns 1 if x == y and 0 otherwise, without branching.
+func radixMatch(x, y uint64) uint64 {
+	_, borrow := bits.Sub64(x^y, 1, 0)
+	return borrow
+}
+
+// radixPartition moves the lt elements of data whose keys shifted right by shift are
+// smaller than prefix to its front, followed by those whose keys shifted are equal to
+// it. It makes one pass for each, using Lomuto's scheme without branches, since
+// whether an element moves is as unpredictable as its key.
+func radixPartition[T Number](data []T, prefix uint64, shift, lt int, r radix) {
+	m := 0
+	for i, x := range data {
+		data[i] = data[m]
+		data[m] = x
+		_, borrow := bits.Sub64(radixKey(x, r)>>shift, prefix, 0)
+		m += int(borrow)
+	}
+
+	for i, x := range data[lt:] {
+		data[lt+i] = data[m]
+		data[m] = x
+		m += int(radixMatch(radixKey(x, r)>>shift, prefix))
+	}
+}
+
+// countingSelect sorts data, which holds integers of at most 16 bits, by counting the
+// occurrences of each value.
+func countingSelect[T Number](data []T, r radix) {
+	counts := make([]int, 1<<r.width)
+	for _, x := range data {
+		counts[radixKey(x, r)>>r.shift]++
+	}
+
+	i := 0
+	for key, c := range counts {
+		x := T(uint64(key) ^ r.flip)
+		for end := i + c; i < end; i++ {
+			data[i] = x
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package kth
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestRadixSelect(t *testing.T) {
+	rng := rand.New(rand.NewPCG(35, 36))
+
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist, BimodalDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder} {
+			for _, size := range []int{1, 13, 300, 5000, 100000} {
+				input := genDistribution(rng, size, dist)
+				applyOrdering(rng, input, order)
+
+				for _, k := range []int{1, 2, size / 2, size - 1, size} {
+					k = min(max(k, 1), size)
+					name := fmt.Sprintf("n=%d/k=%d/dist=%s/order=%s", size, k, dist, order)
+					t.Run(name, func(t *testing.T) {
+						testRadixSelect(t, input, k, func(x int) int { return x })
+						testRadixSelect(t, input, k, func(x int) int { return x - size/2 })
+						testRadixSelect(t, input, k, func(x int) int8 { return int8(x) })
+						testRadixSelect(t, input, k, func(x int) uint8 { return uint8(x) })
+						testRadixSelect(t, input, k, func(x int) int16 { return int16(x - size/2) })
+						testRadixSelect(t, input, k, func(x int) uint16 { return uint16(x) })
+						testRadixSelect(t, input, k, func(x int) int32 { return int32(x - size/2) })
+						testRadixSelect(t, input, k, func(x int) uint64 { return uint64(x) << 40 })
+						testRadixSelect(t, input, k, func(x int) float32 { return float32(x-size/2) / 3 })
+						testRadixSelect(t, input, k, func(x int) float64 { return float64(x-size/2) * 1e300 })
+					})
+				}
+			}
+		}
+	}
+
+	t.Run("Floats", func(t *testing.T) {
+		specials := []float64{math.NaN(), -math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1), 0}
+		for _, size := range []int{10, 1000, 40000} {
+			input := make([]float64, size)
+			for i := range input {
+				if rng.IntN(4) == 0 {
+					input[i] = specials[rng.IntN(len(specials))]
+				} else {
+					input[i] = rng.NormFloat64()
+				}
+			}
+
+			want := slices.Clone(input)
+			slices.SortFunc(want, radixCompare)
+			for _, k := range []int{1, size / 4, size / 2, size} {
+				data := slices.Clone(input)
+				RadixSelect(data, k)
+				if !sameFloat(data[k-1], want[k-1]) || math.Signbit(data[k-1]) != math.Signbit(want[k-1]) {
+					t.Fatalf("n=%d/k=%d: k-th element = %v, want %v", size, k, data[k-1], want[k-1])
+				}
+				for i, x := range data {
+					if c := radixCompare(x, want[k-1]); i < k && c > 0 || i >= k && c < 0 {
+						t.Fatalf("n=%d/k=%d: element %v at index %d is on the wrong side of %v", size, k, x, i, want[k-1])
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("Out of range", func(t *testing.T) {
+		for _, k := range []int{0, 4} {
+			data := []uint8{3, 1, 2}
+			RadixSelect(data, k)
+			if !slices.Equal(data, []uint8{3, 1, 2}) {
+				t.Fatalf("k=%d: data was modified: %v", k, data)
+			}
+		}
+	})
+}
+
+// testRadixSelect checks RadixSelect against a sorted copy of input, converted to T.
+func testRadixSelect[T Number](t *testing.T, input []int, k int, conv func(int) T) {
+	t.Helper()
+
+	data := make([]T, len(input))
+	for i, x := range input {
+		data[i] = conv(x)
+	}
+	sorted := slices.Clone(data)
+	slices.Sort(sorted)
+
+	RadixSelect(data, k)
+	if data[k-1] != sorted[k-1] {
+		t.Fatalf("%T: k-th element = %v, want %v", data, data[k-1], sorted[k-1])
+	}
+	for i, x := range data {
+		if i < k && x > sorted[k-1] || i >= k && x < sorted[k-1] {
+			t.Fatalf("%T: element %v at index %d is on the wrong side of %v", data, x, i, sorted[k-1])
+		}
+	}
+	slices.Sort(data)
+	if !slices.Equal(data, sorted) {
+		t.Fatalf("%T: data is not a permutation of the input", data)
+	}
+}
+
+// radixCompare compares floats according to IEEE 754's totalOrder predicate, without
+// telling NaN payloads apart.
+func radixCompare(a, b float64) int {
+	rank := func(x float64) int {
+		switch {
+		case !math.IsNaN(x):
+			return 0
+		case math.Signbit(x):
+			return -1
+		default:
+			return 1
+		}
+	}
+	if c := cmp.Compare(rank(a), rank(b)); c != 0 || math.IsNaN(a) {
+		return c
+	}
+	return totalCompare(a, b)
+}
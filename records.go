@@ -0,0 +1,424 @@
+package kth
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"unsafe"
+)
+
+const (
+	// defaultRecordsMemoryBudget is the memory budget SelectRecords uses by default.
+	defaultRecordsMemoryBudget = 64 << 20
+
+	// maxRecordsReadSize bounds the size of the reads SelectRecords makes while scanning.
+	maxRecordsReadSize = 1 << 20
+)
+
+// ErrRecordsChanged is returned by SelectRecords when a pass over the records disagrees
+// with an earlier one, as when they're written to while being selected from.
+var ErrRecordsChanged = errors.New("kth: records changed between passes")
+
+// RecordsOptions configures SelectRecords. The zero value uses a 64 MiB memory budget and
+// the sample size and confidence ApproxSelect defaults to.
+type RecordsOptions struct {
+	// MemoryBudget is the number of bytes SelectRecords may use for its buffers. It
+	// bounds the number of records whose keys are held in memory at once, and with it
+	// the number of passes over the records. If it's not positive, 64 MiB are used.
+	// Memory referenced by keys, such as the bytes of strings, isn't accounted for.
+	MemoryBudget int
+
+	// SampleSize is the number of records read at random positions to pick the first
+	// band of keys. If it's not positive, 16384 records are read. It's capped by what
+	// the memory budget allows for the sample.
+	SampleSize int
+
+	// Confidence is the probability with which each band holds the k-th record, within
+	// (0, 1). If it's zero, 0.99 is used. A band that misses costs another pass.
+	Confidence float64
+
+	// Rand is the source of the samples. If it's nil, the global source of math/rand/v2
+	// is used.
+	Rand *rand.Rand
+}
+
+// SelectRecords finds the record with the k-th smallest key among the records of
+// recordSize bytes stored back to back in the first size bytes of r, such as a file of
+// fixed-width little-endian records, and returns a copy of it along with its offset in
+// r. The key of each record is extracted by key, which must not retain the slice it's
+// given, nor return NaNs. Records with equal keys are equivalent, any of them may be
+// returned. r is only read, however large it is, using about opts.MemoryBudget bytes.
+//
+// When the keys of all records fit within the budget, they are read in one pass and the
+// k-th one is selected with PDQSelectByKeys, along with the offsets of their records.
+// Otherwise, SelectRecords narrows down a band of keys that holds the k-th one until
+// the band fits. It reads a sample of records at random positions and picks, as
+// ApproxSelect does, two keys of the sample that bracket the k-th one with the
+// requested confidence. A pass over the records then counts the keys below the band
+// and equal to either end, filters the keys within the band into memory, and samples
+// them for the next band, should they not all fit. The passes carry on within the
+// part of the keys that holds the k-th one, which is the band unless it missed, and
+// each one shrinks the band by a factor of about the square root of the sample size,
+// which the budget bounds: a billion records take two or three passes with the default
+// budget.
+//
+// SelectRecords returns a *KOutOfRangeError if k is not within [1, size/recordSize], an
+// error if size isn't a multiple of recordSize, ErrRecordsChanged if the records change
+// between passes, and the first error r returns. It
+// panics if recordSize isn't positive, if the budget can't hold a read of recordSize
+// bytes and a few hundred keys, or if opts.Confidence is not within [0, 1).
+func SelectRecords[K cmp.Ordered](r io.ReaderAt, size int64, recordSize int, k int, key func(record []byte) K, opts RecordsOptions) (record []byte, offset int64, err error) {
+	if recordSize < 1 {
+		panic("kth: record size out of range")
+	}
+	if size < 0 || size%int64(recordSize) != 0 {
+		return nil, 0, fmt.Errorf("kth: size %d is not a multiple of the record size %d", size, recordSize)
+	}
+	n := size / int64(recordSize)
+	if k < 1 || int64(k) > n {
+		return nil, 0, &KOutOfRangeError{K: k, N: int(min(n, math.MaxInt))}
+	}
+
+	s := newRecordsSelector(r, size, recordSize, key, opts)
+	offset, err = s.run(int64(k), n)
+	if err != nil {
+		return nil, 0, err
+	}
+	record = make([]byte, recordSize)
+	if err := s.readAt(record, offset); err != nil {
+		return nil, 0, err
+	}
+	return record, offset, nil
+}
+
+// recordsSelector holds the state of SelectRecords.
+type recordsSelector[K cmp.Ordered] struct {
+	r          io.ReaderAt
+	size       int64
+	recordSize int
+	key        func(record []byte) K
+	confidence float64
+	int64N     func(n int64) int64
+
+	buf       []byte // read buffer, a multiple of recordSize
+	capacity  int    // the number of keys and offsets that fit in memory
+	reservoir int    // the number of keys sampled in each pass
+	sample    int    // the number of records sampled at random positions
+
+	samples []K     // the buffer of the current sample
+	keys    []K     // the keys filtered into memory
+	offs    []int64 // the offsets of their records
+}
+
+func newRecordsSelector[K cmp.Ordered](r io.ReaderAt, size int64, recordSize int, key func([]byte) K, opts RecordsOptions) *recordsSelector[K] {
+	budget := opts.MemoryBudget
+	if budget <= 0 {
+		budget = defaultRecordsMemoryBudget
+	}
+	confidence := opts.Confidence
+	if confidence == 0 {
+		confidence = defaultApproxConfidence
+	}
+	if !(confidence > 0 && confidence < 1) {
+		panic("kth: confidence out of range")
+	}
+	int64N := rand.Int64N
+	if opts.Rand != nil {
+		int64N = opts.Rand.Int64N
+	}
+
+	// A sixteenth of the budget goes to the read buffer, a quarter of the rest to the
+	// samples, and the remainder to the keys and offsets filtered into memory.
+	var zero K
+	keySize := int(unsafe.Sizeof(zero))
+	bufSize := max(min(budget/16, maxRecordsReadSize)/recordSize, 1) * recordSize
+	rest := budget - bufSize
+	reservoir := rest / 4 / keySize
+	capacity := (rest - reservoir*keySize) / (keySize + 8)
+	if reservoir < 64 || capacity < 64 {
+		panic("kth: memory budget too small")
+	}
+
+	sample := opts.SampleSize
+	if sample <= 0 {
+		sample = defaultApproxSampleSize
+	}
+
+	return &recordsSelector[K]{
+		r:          r,
+		size:       size,
+		recordSize: recordSize,
+		key:        key,
+		confidence: confidence,
+		int64N:     int64N,
+		buf:        make([]byte, bufSize),
+		capacity:   capacity,
+		reservoir:  reservoir,
+		sample:     min(sample, reservoir),
+	}
+}
+
+// recordsBand is an open interval of keys, unbounded on the sides that have no bound.
+type recordsBand[K cmp.Ordered] struct {
+	lo, hi       K
+	hasLo, hasHi bool
+}
+
+func (b *recordsBand[K]) contains(x K) bool {
+	return (!b.hasLo || x > b.lo) && (!b.hasHi || x < b.hi)
+}
+
+// recordsSplit is the outcome of a pass that splits the keys within a band around p1
+// and p2, either of which may be missing, leaving that side of the band as it was.
+type recordsSplit[K cmp.Ordered] struct {
+	p1, p2       K
+	hasP1, hasP2 bool
+
+	// The number of keys within the band below p1, equal to it, between p1 and p2, equal
+	// to p2, and above it.
+	below, eq1, mid, eq2, above int64
+
+	// The offsets of the first records whose keys are p1 and p2.
+	off1, off2 int64
+
+	// Whether the keys between p1 and p2 didn't all fit in memory.
+	overflow bool
+}
+
+// run returns the offset of a record with the k-th smallest key, with k 1-based, among
+// the n records.
+func (s *recordsSelector[K]) run(k, n int64) (int64, error) {
+	var band recordsBand[K]
+	var sample []K
+	count := n // The number of keys within band, among which the k-th smallest is sought.
+
+	for count > int64(s.capacity) {
+		var err error
+		if sample == nil {
+			// The band is either all keys, which are sampled at random positions, or the
+			// part of them a bracket missed, which takes a pass to sample.
+			if !band.hasLo && !band.hasHi {
+				sample, err = s.sampleAt(n)
+			} else {
+				sample, err = s.sampleBand(band, count)
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		// Pick the two keys of the sample that bracket the k-th smallest key within the
+		// band, as ApproxSelect does. When the sample is too small to bracket it on either
+		// side, its estimate of the key splits the band instead.
+		m := len(sample)
+		eps := math.Sqrt(math.Log(2/(1-s.confidence)) / float64(2*m))
+		mf, cf, kf := float64(m), float64(count), float64(k)
+		lo := int(math.Ceil(mf * (kf/cf - eps)))
+		hi := int(math.Floor(mf*((kf-1)/cf+eps))) + 1
+		if lo < 1 && hi > m {
+			lo = min(max(int(math.Ceil(kf*mf/cf)), 1), m)
+			hi = lo
+		}
+		var ranks []int
+		if lo >= 1 {
+			ranks = append(ranks, lo-1)
+		}
+		if hi <= m && hi != lo {
+			ranks = append(ranks, hi-1)
+		}
+		pdqselectMultiOrdered(sample, 0, m, ranks, bits.Len(uint(m)))
+
+		var split recordsSplit[K]
+		if lo >= 1 {
+			split.p1, split.hasP1 = sample[lo-1], true
+		}
+		if hi <= m {
+			split.p2, split.hasP2 = sample[hi-1], true
+		}
+		if sample, err = s.split(band, &split, count); err != nil {
+			return 0, err
+		}
+
+		switch {
+		case k <= split.below:
+			// The bracket missed: the k-th smallest key is below it.
+			band.hi, band.hasHi = split.p1, true
+			count, sample = split.below, nil
+		case k <= split.below+split.eq1:
+			return split.off1, nil
+		case k <= split.below+split.eq1+split.mid:
+			k -= split.below + split.eq1
+			if split.hasP1 {
+				band.lo, band.hasLo = split.p1, true
+			}
+			if split.hasP2 {
+				band.hi, band.hasHi = split.p2, true
+			}
+			if !split.overflow {
+				return s.selectKth(k)
+			}
+			count = split.mid
+		case k <= split.below+split.eq1+split.mid+split.eq2:
+			return split.off2, nil
+		default:
+			// The bracket missed: the k-th smallest key is above it.
+			k -= split.below + split.eq1 + split.mid + split.eq2
+			band.lo, band.hasLo = split.p2, true
+			count, sample = split.above, nil
+		}
+	}
+	return s.filter(band, k, count)
+}
+
+// sampleAt returns the keys of records read at random positions among the n records.
+func (s *recordsSelector[K]) sampleAt(n int64) ([]K, error) {
+	sample := s.sampleBuf()
+	record := s.buf[:s.recordSize]
+	for range s.sample {
+		if err := s.readAt(record, s.int64N(n)*int64(s.recordSize)); err != nil {
+			return nil, err
+		}
+		sample = append(sample, s.key(record))
+	}
+	return sample, nil
+}
+
+// sampleBand returns a sample of the count keys within band, in one pass.
+func (s *recordsSelector[K]) sampleBand(band recordsBand[K], count int64) ([]K, error) {
+	sample := s.sampleBuf()
+	seen := int64(0)
+	err := s.scan(func(x K, _ int64) {
+		if band.contains(x) {
+			seen++
+			sample = s.offer(sample, x, seen)
+		}
+	})
+	if err == nil && seen != count {
+		err = ErrRecordsChanged
+	}
+	return sample, err
+}
+
+// split counts the keys within band around the ends of split in one pass, filtering the
+// keys between them into memory, and returns a sample of those keys.
+func (s *recordsSelector[K]) split(band recordsBand[K], split *recordsSplit[K], count int64) ([]K, error) {
+	sample := s.sampleBuf()
+	s.keys, s.offs = s.keys[:0], s.offs[:0]
+	err := s.scan(func(x K, off int64) {
+		switch {
+		case !band.contains(x):
+		case split.hasP1 && x < split.p1:
+			split.below++
+		case split.hasP1 && x == split.p1:
+			if split.eq1++; split.eq1 == 1 {
+				split.off1 = off
+			}
+		case !split.hasP2 || x < split.p2:
+			split.mid++
+			sample = s.offer(sample, x, split.mid)
+			if len(s.keys) < s.capacity {
+				s.keys = append(s.keys, x)
+				s.offs = append(s.offs, off)
+			} else {
+				split.overflow = true
+			}
+		case x == split.p2:
+			if split.eq2++; split.eq2 == 1 {
+				split.off2 = off
+			}
+		default:
+			split.above++
+		}
+	})
+	// The ends were drawn from the keys within band, so they must have been met, which
+	// guarantees that each pass narrows it down. Keys equal to both are counted as p1's.
+	missed := split.hasP1 && split.eq1 == 0 ||
+		split.hasP2 && split.eq2 == 0 && !(split.hasP1 && split.p1 == split.p2)
+	if err == nil && (missed || split.below+split.eq1+split.mid+split.eq2+split.above != count) {
+		err = ErrRecordsChanged
+	}
+	return sample, err
+}
+
+// filter reads the count keys within band into memory, along with the offsets of their
+// records, and returns the offset of the record with the k-th smallest key among them.
+func (s *recordsSelector[K]) filter(band recordsBand[K], k, count int64) (int64, error) {
+	s.keys, s.offs = s.keys[:0], s.offs[:0]
+	err := s.scan(func(x K, off int64) {
+		if band.contains(x) && len(s.keys) < s.capacity {
+			s.keys = append(s.keys, x)
+			s.offs = append(s.offs, off)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	if int64(len(s.keys)) != count {
+		return 0, ErrRecordsChanged
+	}
+	return s.selectKth(k)
+}
+
+// selectKth returns the offset of the record with the k-th smallest key among the keys
+// in memory.
+func (s *recordsSelector[K]) selectKth(k int64) (int64, error) {
+	n := len(s.keys)
+	if k > int64(n) {
+		return 0, ErrRecordsChanged
+	}
+	pdqselectByKeys(s.keys, s.offs, 0, n, int(k-1), bits.Len(uint(n)))
+	return s.offs[k-1], nil
+}
+
+// sampleBuf returns the empty buffer samples are drawn into, which each sample reuses,
+// as the previous one is no longer needed once the next one is drawn.
+func (s *recordsSelector[K]) sampleBuf() []K {
+	if s.samples == nil {
+		s.samples = make([]K, 0, s.reservoir)
+	}
+	return s.samples[:0]
+}
+
+// offer adds x, the seen-th key of a stream, to the sample of it drawn so far, keeping
+// each key with the same probability by reservoir sampling.
+func (s *recordsSelector[K]) offer(sample []K, x K, seen int64) []K {
+	if len(sample) < s.reservoir {
+		return append(sample, x)
+	}
+	if j := s.int64N(seen); j < int64(len(sample)) {
+		sample[j] = x
+	}
+	return sample
+}
+
+// scan calls fn with the key and offset of every record, in order.
+func (s *recordsSelector[K]) scan(fn func(x K, off int64)) error {
+	rs := s.recordSize
+	for off := int64(0); off < s.size; off += int64(len(s.buf)) {
+		buf := s.buf[:min(int64(len(s.buf)), s.size-off)]
+		if err := s.readAt(buf, off); err != nil {
+			return err
+		}
+		for i := 0; i < len(buf); i += rs {
+			fn(s.key(buf[i:i+rs:i+rs]), off+int64(i))
+		}
+	}
+	return nil
+}
+
+// readAt fills p from r at off, treating a short read as an error.
+func (s *recordsSelector[K]) readAt(p []byte, off int64) error {
+	n, err := s.r.ReadAt(p, off)
+	switch {
+	case n == len(p):
+		return nil
+	case err == nil || err == io.EOF:
+		return io.ErrUnexpectedEOF
+	default:
+		return err
+	}
+}
@@ -0,0 +1,187 @@
+package kth
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// recordSize is the size of the records of the SelectRecords tests: a little-endian
+// int64 key followed by the index of the record.
+const recordSize = 16
+
+func encodeRecords(keys []int) []byte {
+	b := make([]byte, 0, len(keys)*recordSize)
+	for i, key := range keys {
+		b = binary.LittleEndian.AppendUint64(b, uint64(key))
+		b = binary.LittleEndian.AppendUint64(b, uint64(i))
+	}
+	return b
+}
+
+func recordKey(record []byte) int64 {
+	return int64(binary.LittleEndian.Uint64(record))
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.ReaderAt
+	n int64
+}
+
+func (r *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.r.ReadAt(p, off)
+	r.n += int64(n)
+	return n, err
+}
+
+func TestSelectRecords(t *testing.T) {
+	rng := rand.New(rand.NewPCG(47, 48))
+
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist, BimodalDist, NormalDist} {
+		for _, size := range []int{1, 100, 50000} {
+			input := genDistribution(rng, size, dist)
+			applyOrdering(rng, input, RandomOrder)
+			file := encodeRecords(input)
+			sorted := slices.Clone(input)
+			slices.Sort(sorted)
+
+			// The smaller budgets only fit a few hundred keys in memory, which takes many
+			// passes, and the smallest sample size makes the brackets miss.
+			for _, opts := range []RecordsOptions{
+				{Rand: rng},
+				{MemoryBudget: 8 << 10, Rand: rng},
+				{MemoryBudget: 16 << 10, SampleSize: 100, Confidence: 0.5, Rand: rng},
+			} {
+				for _, k := range []int{1, 2, size / 3, size / 2, size - 1, size} {
+					if k < 1 || k > size {
+						continue
+					}
+					name := fmt.Sprintf("n=%d/k=%d/dist=%s/budget=%d", size, k, dist, opts.MemoryBudget)
+					t.Run(name, func(t *testing.T) {
+						record, offset, err := SelectRecords(bytes.NewReader(file), int64(len(file)), recordSize, k, recordKey, opts)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if offset%recordSize != 0 || !bytes.Equal(record, file[offset:offset+recordSize]) {
+							t.Fatalf("record %x at offset %d, want %x", record, offset, file[offset:offset+recordSize])
+						}
+						if got := recordKey(record); got != int64(sorted[k-1]) {
+							t.Fatalf("key = %d, want %d", got, sorted[k-1])
+						}
+					})
+				}
+			}
+		}
+	}
+
+	t.Run("Passes", func(t *testing.T) {
+		input := genDistribution(rng, 1<<20, UniformDist)
+		file := encodeRecords(input)
+		sorted := slices.Clone(input)
+		slices.Sort(sorted)
+
+		// A million keys fit within the default budget, and are read in one pass. With 6 MiB,
+		// about 3% of the keys are bracketed by the first sample, which fit in memory after
+		// one pass too. With 512 KiB, they don't, and the sample taken during the first pass
+		// brackets few enough for the second pass.
+		for _, tc := range []struct {
+			budget int
+			passes float64
+		}{{0, 1}, {6 << 20, 1.1}, {512 << 10, 2.1}} {
+			r := &countingReader{r: bytes.NewReader(file)}
+			opts := RecordsOptions{MemoryBudget: tc.budget, Rand: rng}
+			record, _, err := SelectRecords(r, int64(len(file)), recordSize, len(input)/2, recordKey, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := recordKey(record); got != int64(sorted[len(input)/2-1]) {
+				t.Fatalf("budget %d: key = %d, want %d", tc.budget, got, sorted[len(input)/2-1])
+			}
+			// The records are read in full on every pass, and some more at random.
+			if passes := float64(r.n-recordSize) / float64(len(file)); passes > tc.passes {
+				t.Errorf("budget %d: read %.2f times the records, want at most %v", tc.budget, passes, tc.passes)
+			}
+		}
+	})
+
+	t.Run("String keys", func(t *testing.T) {
+		input := genDistribution(rng, 20000, ZipfDist)
+		file := encodeRecords(input)
+		key := func(record []byte) string { return fmt.Sprint(recordKey(record)) }
+		want := make([]string, len(input))
+		for i, x := range input {
+			want[i] = fmt.Sprint(x)
+		}
+		slices.Sort(want)
+		for _, k := range []int{1, 5000, 20000} {
+			record, _, err := SelectRecords(bytes.NewReader(file), int64(len(file)), recordSize, k, key, RecordsOptions{MemoryBudget: 8 << 10, Rand: rng})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := key(record); got != want[k-1] {
+				t.Errorf("k=%d: key = %q, want %q", k, got, want[k-1])
+			}
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		file := encodeRecords(genDistribution(rng, 100000, UniformDist))
+		size := int64(len(file))
+
+		for _, k := range []int{0, 100001} {
+			if _, _, err := SelectRecords(bytes.NewReader(file), size, recordSize, k, recordKey, RecordsOptions{}); !errors.Is(err, ErrKOutOfRange) {
+				t.Errorf("k=%d: err = %v, want ErrKOutOfRange", k, err)
+			}
+		}
+		if _, _, err := SelectRecords(bytes.NewReader(file), size-1, recordSize, 1, recordKey, RecordsOptions{}); err == nil {
+			t.Error("SelectRecords of a partial record succeeded")
+		}
+		if _, _, err := SelectRecords(bytes.NewReader(file[:size/2]), size, recordSize, 1, recordKey, RecordsOptions{}); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("truncated file: err = %v, want io.ErrUnexpectedEOF", err)
+		}
+
+		// Records that change between passes are detected rather than trusted.
+		changing := &changingReader{file: file}
+		if _, _, err := SelectRecords(changing, size, recordSize, 50000, recordKey, RecordsOptions{MemoryBudget: 8 << 10, Rand: rng}); !errors.Is(err, ErrRecordsChanged) {
+			t.Errorf("changing records: err = %v, want ErrRecordsChanged", err)
+		}
+	})
+}
+
+// changingReader adds to the keys of the records it reads on every read that starts at
+// offset 0, as if they were rewritten between passes.
+type changingReader struct {
+	file  []byte
+	delta uint64
+}
+
+func (r *changingReader) ReadAt(p []byte, off int64) (int, error) {
+	if off == 0 {
+		r.delta += 1 << 40
+	}
+	n := copy(p, r.file[off:])
+	for i := 0; i < n; i += recordSize {
+		binary.LittleEndian.PutUint64(p[i:], binary.LittleEndian.Uint64(r.file[off+int64(i):])+r.delta)
+	}
+	return n, nil
+}
+
+func ExampleSelectRecords() {
+	// Records of 8 bytes: a little-endian uint32 ID followed by a uint32 score.
+	var file []byte
+	for id, score := range []uint32{50, 20, 90, 70, 10} {
+		file = binary.LittleEndian.AppendUint32(file, uint32(id))
+		file = binary.LittleEndian.AppendUint32(file, score)
+	}
+	score := func(record []byte) uint32 { return binary.LittleEndian.Uint32(record[4:]) }
+
+	record, offset, _ := SelectRecords(bytes.NewReader(file), int64(len(file)), 8, 2, score, RecordsOptions{})
+	fmt.Println(binary.LittleEndian.Uint32(record), score(record), offset)
+	// Output: 1 20 8
+}
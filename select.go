@@ -0,0 +1,226 @@
+package kth
+
+import (
+	"cmp"
+	"sort"
+	"strconv"
+)
+
+// Algorithm is a selection algorithm that Select can dispatch to.
+type Algorithm int
+
+const (
+	// PDQSelectAlgorithm stands for PDQSelect.
+	PDQSelectAlgorithm Algorithm = iota
+
+	// FloydRivestAlgorithm stands for FloydRivest.
+	FloydRivestAlgorithm
+)
+
+// String returns the name of the function implementing a.
+func (a Algorithm) String() string {
+	switch a {
+	case PDQSelectAlgorithm:
+		return "PDQSelect"
+	case FloydRivestAlgorithm:
+		return "FloydRivest"
+	default:
+		return "Algorithm(" + strconv.Itoa(int(a)) + ")"
+	}
+}
+
+const (
+	// adaptiveThreshold is the size below which Select always uses PDQSelect.
+	adaptiveThreshold = 1 << 14
+
+	// probeSize is the number of elements Select samples to decide on an algorithm.
+	probeSize = 32
+
+	// probeDuplicates is the number of elements of the sample equal to its estimate of
+	// the k-th smallest element from which Select uses pdqselect.
+	probeDuplicates = 6
+)
+
+// Select swaps elements in the data provided so that the first k elements are the
+// smallest k elements in the data, like PDQSelect and FloydRivest do, using whichever
+// of the two is expected to be faster on data.
+//
+// FloydRivest is faster on most inputs, but its two-way partitioning degrades when many
+// elements are equal to the k-th smallest one, and it doesn't benefit from reversed
+// inputs as much as pdqselect does. Select therefore samples 32 evenly spaced elements
+// and uses PDQSelect if at least 6 of them are equal to the sample's estimate of the
+// k-th smallest element, or if the sample is strictly descending and k lies within the
+// middle half of data. It also uses PDQSelect when data holds fewer than 16384 elements,
+// as the probe would then cost more than FloydRivest can save. Otherwise, it uses
+// FloydRivest. Use ChooseAlgorithm to find out which one is picked for a given input.
+//
+// Like PDQSelect, it leaves data untouched when k is not within [1, n].
+func Select(data sort.Interface, k int) {
+	n := data.Len()
+	if k < 1 || k > n {
+		return
+	}
+	switch ChooseAlgorithm(data, k) {
+	case FloydRivestAlgorithm:
+		FloydRivest(data, k)
+	default:
+		PDQSelect(data, k)
+	}
+}
+
+// SelectOrdered is a specialized version of Select that works with slices of ordered
+// types (i.e. types that implement the cmp.Ordered interface).
+// Like PDQSelect, it leaves data untouched when k is not within [1, len(data)].
+func SelectOrdered[T cmp.Ordered](data []T, k int) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	switch ChooseAlgorithmOrdered(data, k) {
+	case FloydRivestAlgorithm:
+		FloydRivestOrdered(data, k)
+	default:
+		PDQSelectOrdered(data, k)
+	}
+}
+
+// SelectFunc is a generic version of Select that allows the caller to provide a custom
+// comparison function to determine the order of elements.
+// Like PDQSelect, it leaves data untouched when k is not within [1, len(data)].
+func SelectFunc[E any](data []E, k int, less func(a, b E) bool) {
+	n := len(data)
+	if k < 1 || k > n {
+		return
+	}
+	switch ChooseAlgorithmFunc(data, k, less) {
+	case FloydRivestAlgorithm:
+		FloydRivestFunc(data, k, less)
+	default:
+		PDQSelectFunc(data, k, less)
+	}
+}
+
+// ChooseAlgorithm returns the algorithm Select would use to find the k-th smallest
+// element of data, without modifying data. As elements can't be copied out of a
+// sort.Interface, it ranks the sample by comparing every pair of elements, which
+// takes 992 comparisons. The slice variants sort a copy of the sample instead.
+func ChooseAlgorithm(data sort.Interface, k int) Algorithm {
+	n := data.Len()
+	if n < adaptiveThreshold || k < 1 || k > n {
+		return PDQSelectAlgorithm
+	}
+
+	step := n / probeSize
+	var pos, smaller, equal [probeSize]int
+	for i := range pos {
+		pos[i] = i*step + step/2
+	}
+	descending := true
+	for i := range pos {
+		for j := i + 1; j < probeSize; j++ {
+			switch {
+			case data.Less(pos[i], pos[j]):
+				smaller[j]++
+			case data.Less(pos[j], pos[i]):
+				smaller[i]++
+				continue
+			default:
+				equal[i]++
+				equal[j]++
+			}
+			if j == i+1 {
+				descending = false
+			}
+		}
+	}
+
+	// The sample elements equal to the one ranked r occupy the ranks from smaller
+	// to smaller+equal of the sample.
+	r := (k - 1) * probeSize / n
+	for i := range pos {
+		if smaller[i] <= r && r <= smaller[i]+equal[i] {
+			return chooseAlgorithm(n, k, descending, equal[i]+1)
+		}
+	}
+	// No element has rank r if Less is inconsistent on the sample, e.g. cyclic, which
+	// pdqselect copes with.
+	return PDQSelectAlgorithm
+}
+
+// ChooseAlgorithmOrdered returns the algorithm SelectOrdered would use to find the
+// k-th smallest element of data, without modifying data.
+func ChooseAlgorithmOrdered[T cmp.Ordered](data []T, k int) Algorithm {
+	n := len(data)
+	if n < adaptiveThreshold || k < 1 || k > n {
+		return PDQSelectAlgorithm
+	}
+
+	step := n / probeSize
+	var sample [probeSize]T
+	descending := true
+	for i := range sample {
+		sample[i] = data[i*step+step/2]
+		if i > 0 && !cmp.Less(sample[i], sample[i-1]) {
+			descending = false
+		}
+	}
+	insertionSortOrdered(sample[:], 0, probeSize)
+
+	x := sample[(k-1)*probeSize/n]
+	equal := 0
+	for _, y := range sample {
+		if !cmp.Less(x, y) && !cmp.Less(y, x) {
+			equal++
+		}
+	}
+	return chooseAlgorithm(n, k, descending, equal)
+}
+
+// ChooseAlgorithmFunc returns the algorithm SelectFunc would use to find the k-th
+// smallest element of data, without modifying data.
+func ChooseAlgorithmFunc[E any](data []E, k int, less func(a, b E) bool) Algorithm {
+	n := len(data)
+	if n < adaptiveThreshold || k < 1 || k > n {
+		return PDQSelectAlgorithm
+	}
+
+	step := n / probeSize
+	var sample [probeSize]E
+	descending := true
+	for i := range sample {
+		sample[i] = data[i*step+step/2]
+		if i > 0 && !less(sample[i], sample[i-1]) {
+			descending = false
+		}
+	}
+	insertionSortLessFunc(sample[:], 0, probeSize, less)
+
+	x := sample[(k-1)*probeSize/n]
+	equal := 0
+	for _, y := range sample {
+		if !less(x, y) && !less(y, x) {
+			equal++
+		}
+	}
+	return chooseAlgorithm(n, k, descending, equal)
+}
+
+// chooseAlgorithm decides between pdqselect and Floyd-Rivest given whether a sample
+// of probeSize evenly spaced elements among n is strictly descending, and how many
+// elements of the sample are equal to its estimate of the k-th smallest element.
+func chooseAlgorithm(n, k int, descending bool, equal int) Algorithm {
+	// pdqselect gathers the elements equal to a pivot in a single pass, while they
+	// make Floyd-Rivest's two-way partitioning steps discard little.
+	if equal >= probeDuplicates {
+		return PDQSelectAlgorithm
+	}
+
+	// pdqselect reverses descending runs and then finds the k-th element with few
+	// partitioning steps, unless k is close enough to either end for Floyd-Rivest to
+	// discard almost everything with its first one.
+	if descending && n/4 <= k-1 && k-1 < n-n/4 {
+		return PDQSelectAlgorithm
+	}
+
+	return FloydRivestAlgorithm
+}
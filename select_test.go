@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"math/bits"
 	"math/rand/v2"
 	"slices"
 	"sort"
@@ -49,6 +50,12 @@ func TestSelect(t *testing.T) {
 			})
 		})
 
+		t.Run("PDQSelectCmpFunc/"+tc.name, func(t *testing.T) {
+			testSelect(t, tc.input, 0, len(tc.input), tc.k, "PDQSelectCmpFunc", func(input []int, a, b, k int) {
+				PDQSelectCmpFunc(input, k, cmp.Compare)
+			})
+		})
+
 		t.Run("FloydRivest/"+tc.name, func(t *testing.T) {
 			testSelect(t, tc.input, 0, len(tc.input), tc.k, "FloydRivest", func(input []int, a, b, k int) {
 				FloydRivest(sort.IntSlice(input), k)
@@ -66,6 +73,224 @@ func TestSelect(t *testing.T) {
 				FloydRivestFunc(input, k, cmp.Less)
 			})
 		})
+
+		t.Run("FloydRivestCmpFunc/"+tc.name, func(t *testing.T) {
+			testSelect(t, tc.input, 0, len(tc.input), tc.k, "FloydRivestCmpFunc", func(input []int, a, b, k int) {
+				FloydRivestCmpFunc(input, k, cmp.Compare)
+			})
+		})
+
+		t.Run("Select/"+tc.name, func(t *testing.T) {
+			testSelect(t, tc.input, 0, len(tc.input), tc.k, "Select", func(input []int, a, b, k int) {
+				Select(sort.IntSlice(input), k)
+			})
+		})
+
+		t.Run("SelectOrdered/"+tc.name, func(t *testing.T) {
+			testSelect(t, tc.input, 0, len(tc.input), tc.k, "SelectOrdered", func(input []int, a, b, k int) {
+				SelectOrdered(input, k)
+			})
+		})
+
+		t.Run("SelectFunc/"+tc.name, func(t *testing.T) {
+			testSelect(t, tc.input, 0, len(tc.input), tc.k, "SelectFunc", func(input []int, a, b, k int) {
+				SelectFunc(input, k, cmp.Less)
+			})
+		})
+	}
+}
+
+func TestSelectAdaptive(t *testing.T) {
+	rng := rand.New(rand.NewPCG(27, 28))
+
+	for _, dist := range []Distribution{UniformDist, NormalDist, ZipfDist, ConstantDist, BimodalDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder, MostlySorted, PushFrontOrder} {
+			for _, size := range []int{1000, adaptiveThreshold, 50000} {
+				input := genDistribution(rng, size, dist)
+				applyOrdering(rng, input, order)
+				sorted := slices.Clone(input)
+				slices.Sort(sorted)
+
+				for _, k := range []int{1, 100, size / 2, size - 100, size} {
+					name := fmt.Sprintf("n=%d/k=%d/dist=%s/order=%s", size, k, dist, order)
+					t.Run(name, func(t *testing.T) {
+						want := ChooseAlgorithm(sort.IntSlice(input), k)
+						if size < adaptiveThreshold && want != PDQSelectAlgorithm {
+							t.Fatalf("ChooseAlgorithm = %v below the threshold", want)
+						}
+						if got := ChooseAlgorithmOrdered(input, k); got != want {
+							t.Fatalf("ChooseAlgorithmOrdered = %v, want %v", got, want)
+						}
+						if got := ChooseAlgorithmFunc(input, k, cmp.Less); got != want {
+							t.Fatalf("ChooseAlgorithmFunc = %v, want %v", got, want)
+						}
+
+						data := slices.Clone(input)
+						Select(sort.IntSlice(data), k)
+						checkSelected(t, data, sorted, k)
+
+						data = slices.Clone(input)
+						SelectOrdered(data, k)
+						checkSelected(t, data, sorted, k)
+
+						data = slices.Clone(input)
+						SelectFunc(data, k, cmp.Less)
+						checkSelected(t, data, sorted, k)
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestChooseAlgorithm(t *testing.T) {
+	rng := rand.New(rand.NewPCG(29, 30))
+	const n = 1 << 16
+
+	testCases := []struct {
+		name  string
+		dist  Distribution
+		order Ordering
+		k     int
+		want  Algorithm
+	}{
+		{"Distinct", UniformDist, RandomOrder, n / 2, FloydRivestAlgorithm},
+		{"Sorted", UniformDist, SortedOrder, n / 2, FloydRivestAlgorithm},
+		{"All equal", ConstantDist, RandomOrder, n / 2, PDQSelectAlgorithm},
+		{"Frequent minimum", ZipfDist, RandomOrder, 1, PDQSelectAlgorithm},
+		{"Rare maximum", ZipfDist, RandomOrder, n, FloydRivestAlgorithm},
+		{"Reversed", UniformDist, ReversedOrder, n / 2, PDQSelectAlgorithm},
+		{"Reversed near the end", UniformDist, ReversedOrder, n - 100, FloydRivestAlgorithm},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			input := genDistribution(rng, n, tc.dist)
+			applyOrdering(rng, input, tc.order)
+
+			if got := ChooseAlgorithm(sort.IntSlice(input), tc.k); got != tc.want {
+				t.Errorf("ChooseAlgorithm = %v, want %v", got, tc.want)
+			}
+			if got := ChooseAlgorithmOrdered(input, tc.k); got != tc.want {
+				t.Errorf("ChooseAlgorithmOrdered = %v, want %v", got, tc.want)
+			}
+			if got := ChooseAlgorithmFunc(input, tc.k, cmp.Less); got != tc.want {
+				t.Errorf("ChooseAlgorithmFunc = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Inconsistent", func(t *testing.T) {
+		// The sample holds the values 0 to probeSize-1, ordered as ints except for the
+		// largest being less than the smallest, so no element of it ranks first.
+		data := make(cyclicInts, n)
+		for i := range data {
+			data[i] = i * probeSize / n
+		}
+		if got := ChooseAlgorithm(data, 1); got != PDQSelectAlgorithm {
+			t.Errorf("ChooseAlgorithm = %v, want %v", got, PDQSelectAlgorithm)
+		}
+		Select(data, 1) // Must not panic.
+	})
+
+	if got := Algorithm(7).String(); got != "Algorithm(7)" {
+		t.Errorf("Algorithm(7).String() = %q", got)
+	}
+}
+
+func TestPDQSelectCmpFuncComparisons(t *testing.T) {
+	rng := rand.New(rand.NewPCG(49, 50))
+
+	// With many duplicates, the three-way comparator routes the elements equal to the
+	// pivot in the same call that compares them, so PDQSelectCmpFunc must make fewer
+	// calls than PDQSelectFunc makes to less.
+	const n = 100000
+	for _, distinct := range []int{2, 10, 100} {
+		input := make([]int, n)
+		for i := range input {
+			input[i] = rng.IntN(distinct)
+		}
+		for _, k := range []int{n / 10, n / 2, n - n/4} {
+			lessCalls, cmpCalls := 0, 0
+			got := slices.Clone(input)
+			PDQSelectFunc(got, k, func(a, b int) bool { lessCalls++; return a < b })
+			testSelect(t, input, 0, n, k, "PDQSelectCmpFunc", func(data []int, a, b, k int) {
+				PDQSelectCmpFunc(data, k, func(a, b int) int { cmpCalls++; return cmp.Compare(a, b) })
+			})
+			if cmpCalls >= lessCalls {
+				t.Errorf("distinct=%d/k=%d: %d calls to cmp, want fewer than the %d calls to less", distinct, k, cmpCalls, lessCalls)
+			}
+		}
+	}
+}
+
+// cyclicInts orders ints as usual, except for probeSize-1 being less than 0.
+type cyclicInts []int
+
+func (x cyclicInts) Len() int      { return len(x) }
+func (x cyclicInts) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x cyclicInts) Less(i, j int) bool {
+	if x[i] == probeSize-1 && x[j] == 0 {
+		return true
+	}
+	if x[i] == 0 && x[j] == probeSize-1 {
+		return false
+	}
+	return x[i] < x[j]
+}
+
+func TestSelectWithin(t *testing.T) {
+	rng := rand.New(rand.NewPCG(15, 16))
+
+	funcs := []struct {
+		name string
+		fn   func(data []int, a, b, k int)
+	}{
+		{"PDQSelectWithin", func(data []int, a, b, k int) { PDQSelectWithin(sort.IntSlice(data), a, b, k) }},
+		{"PDQSelectWithinOrdered", func(data []int, a, b, k int) { PDQSelectWithinOrdered(data, a, b, k) }},
+		{"PDQSelectWithinFunc", func(data []int, a, b, k int) { PDQSelectWithinFunc(data, a, b, k, cmp.Less) }},
+		{"FloydRivestWithin", func(data []int, a, b, k int) { FloydRivestWithin(sort.IntSlice(data), a, b, k) }},
+		{"FloydRivestWithinOrdered", func(data []int, a, b, k int) { FloydRivestWithinOrdered(data, a, b, k) }},
+		{"FloydRivestWithinFunc", func(data []int, a, b, k int) { FloydRivestWithinFunc(data, a, b, k, cmp.Less) }},
+	}
+
+	for _, f := range funcs {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder} {
+			for _, size := range []int{20, 2000} {
+				// Surround the window with elements that sort both before and after
+				// all of its elements, so that neither can be used as a sentinel.
+				window := genDistribution(rng, size, UniformDist)
+				applyOrdering(rng, window, order)
+				input := slices.Concat([]int{-1, size * 2}, window, []int{-1, size * 2})
+				a, b := 2, 2+size
+
+				for _, k := range []int{a + 1, a + 2, a + size/2, b - 1, b} {
+					name := fmt.Sprintf("%s/n=%d/k=%d/order=%s", f.name, size, k, order)
+					t.Run(name, func(t *testing.T) {
+						testSelect(t, input, a, b, k-a, f.name, func(slice []int, a, b, k int) {
+							f.fn(slice, a, b, a+k)
+						})
+
+						output := slices.Clone(input)
+						f.fn(output, a, b, k)
+						if !slices.Equal(output[:a], input[:a]) || !slices.Equal(output[b:], input[b:]) {
+							t.Errorf("elements outside of the window were modified")
+						}
+					})
+				}
+			}
+		}
+
+		t.Run(f.name+"/Out of range", func(t *testing.T) {
+			input := []int{5, 4, 3, 2, 1}
+			for _, w := range [][3]int{{-1, 3, 1}, {0, 6, 1}, {1, 3, 1}, {1, 3, 4}, {3, 3, 3}} {
+				output := slices.Clone(input)
+				f.fn(output, w[0], w[1], w[2])
+				if !slices.Equal(output, input) {
+					t.Errorf("a=%d, b=%d, k=%d: data was modified: %v", w[0], w[1], w[2], output)
+				}
+			}
+		})
 	}
 }
 
@@ -79,6 +304,9 @@ func FuzzSelect(f *testing.F) {
 	f.Add(encodeInts(1, 4, 7, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1), uint16(7), uint16(3), uint16(12))
 	f.Add(encodeInts(254, 4, 7, 2, 0, 0, 0, 255, 0, 0, 0, 0, 0, 0, 0, 253), uint16(7), uint16(0), uint16(16))
 	f.Add(encodeInts(0, 0, 0, 0, 0, 0, 0, 255, 0, 0, 0, 0, 0, 0, 0, 253, 0, 0, 0, 0, 0, 0), uint16(0), uint16(20), uint16(12))
+	// A range past the insertion sort cutoff that doesn't start at 0, for the heapSelect
+	// fallback of pdqselect, which used to be given k relative to 0 rather than to a.
+	f.Add(encodeInts(19, 3, 17, 5, 15, 7, 13, 9, 11, 1, 18, 2, 16, 4, 14, 6, 12, 8, 10, 0), uint16(5), uint16(2), uint16(18))
 
 	now := time.Now().UnixNano()
 	rng := rand.New(rand.NewPCG(uint64(now), uint64(now>>32)))
@@ -138,6 +366,10 @@ func FuzzSelect(f *testing.F) {
 			PDQSelectOrdered(slice, k)
 		})
 
+		testSelect(t, input, 0, len(input), int(k), "PDQSelectCmpFunc", func(slice []int, a, b, k int) {
+			PDQSelectCmpFunc(slice, k, cmp.Compare)
+		})
+
 		testSelect(t, input, 0, len(input), int(k), "pdqselect", func(slice []int, a, b, k int) {
 			pdqselect(sort.IntSlice(slice), 0, len(slice), k-1, 0)
 		})
@@ -150,6 +382,10 @@ func FuzzSelect(f *testing.F) {
 			pdqselectFunc(slice, 0, len(slice), k-1, 0, cmp.Less)
 		})
 
+		testSelect(t, input, 0, len(input), int(k), "pdqselectCmpFunc", func(slice []int, a, b, k int) {
+			pdqselectCmpFunc(slice, 0, len(slice), k-1, 0, cmp.Compare)
+		})
+
 		testSelect(t, input, 0, len(input), int(k), "FloydRivest", func(slice []int, a, b, k int) {
 			FloydRivest(sort.IntSlice(slice), k)
 		})
@@ -162,16 +398,24 @@ func FuzzSelect(f *testing.F) {
 			FloydRivestFunc(slice, k, cmp.Less)
 		})
 
+		testSelect(t, input, 0, len(input), int(k), "FloydRivestCmpFunc", func(slice []int, a, b, k int) {
+			FloydRivestCmpFunc(slice, k, cmp.Compare)
+		})
+
 		testSelect(t, input, 0, len(input), int(k), "floydRivestSelect", func(slice []int, a, b, k int) {
-			floydRivest(sort.IntSlice(slice), 0, len(slice)-1, k-1)
+			floydRivest(sort.IntSlice(slice), 0, len(slice)-1, k-1, bits.Len(uint(len(slice))))
 		})
 
 		testSelect(t, input, 0, len(input), int(k), "floydRivestOrdered", func(slice []int, a, b, k int) {
-			floydRivestOrdered(slice, 0, len(slice)-1, k-1)
+			floydRivestOrdered(slice, 0, len(slice)-1, k-1, bits.Len(uint(len(slice))))
 		})
 
 		testSelect(t, input, 0, len(input), int(k), "floydRivestFunc", func(slice []int, a, b, k int) {
-			floydRivestFunc(slice, 0, len(slice)-1, k-1, cmp.Less)
+			floydRivestFunc(slice, 0, len(slice)-1, k-1, bits.Len(uint(len(slice))), cmp.Less)
+		})
+
+		testSelect(t, input, 0, len(input), int(k), "floydRivestCmpFunc", func(slice []int, a, b, k int) {
+			floydRivestCmpFunc(slice, 0, len(slice)-1, k-1, bits.Len(uint(len(slice))), cmp.Compare)
 		})
 
 		// Ensure a, b, and k are within bounds
@@ -200,6 +444,74 @@ func FuzzSelect(f *testing.F) {
 		testSelect(t, input, int(a), int(b), int(k), "heapSelectFunc", func(slice []int, a, b, k int) {
 			heapSelectFunc(slice, a, b, k-1, cmp.Less)
 		})
+
+		testSelect(t, input, int(a), int(b), int(k), "heapSelectCmpFunc", func(slice []int, a, b, k int) {
+			heapSelectCmpFunc(slice, a, b, k-1, cmp.Compare)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "pdqselect/limit=0", func(slice []int, a, b, k int) {
+			pdqselect(sort.IntSlice(slice), a, b, a+k-1, 0)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "pdqselectOrdered/limit=0", func(slice []int, a, b, k int) {
+			pdqselectOrdered(slice, a, b, a+k-1, 0)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "pdqselectFunc/limit=0", func(slice []int, a, b, k int) {
+			pdqselectFunc(slice, a, b, a+k-1, 0, cmp.Less)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "pdqselectFallbackOrdered/limit=0", func(slice []int, a, b, k int) {
+			pdqselectFallbackOrdered(slice, a, b, a+k-1, 0, MedianOfMediansFallback)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "pdqselectByKeys/limit=0", func(slice []int, a, b, k int) {
+			pdqselectByKeys(slice, make([]struct{}, len(slice)), a, b, a+k-1, 0)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "floydRivestByKeys", func(slice []int, a, b, k int) {
+			floydRivestByKeys(slice, make([]struct{}, len(slice)), a, b-1, a+k-1, bits.Len(uint(b-a)))
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "radixSelect", func(slice []int, a, b, k int) {
+			radixSelect(slice[a:b], k-1, newRadix[int]())
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "medianOfMedians", func(slice []int, a, b, k int) {
+			medianOfMedians(sort.IntSlice(slice), a, b, a+k-1)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "medianOfMediansOrdered", func(slice []int, a, b, k int) {
+			medianOfMediansOrdered(slice, a, b, a+k-1)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "medianOfMediansFunc", func(slice []int, a, b, k int) {
+			medianOfMediansFunc(slice, a, b, a+k-1, cmp.Less)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "PDQSelectWithin", func(slice []int, a, b, k int) {
+			PDQSelectWithin(sort.IntSlice(slice), a, b, a+k)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "PDQSelectWithinOrdered", func(slice []int, a, b, k int) {
+			PDQSelectWithinOrdered(slice, a, b, a+k)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "PDQSelectWithinFunc", func(slice []int, a, b, k int) {
+			PDQSelectWithinFunc(slice, a, b, a+k, cmp.Less)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "FloydRivestWithin", func(slice []int, a, b, k int) {
+			FloydRivestWithin(sort.IntSlice(slice), a, b, a+k)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "FloydRivestWithinOrdered", func(slice []int, a, b, k int) {
+			FloydRivestWithinOrdered(slice, a, b, a+k)
+		})
+
+		testSelect(t, input, int(a), int(b), int(k), "FloydRivestWithinFunc", func(slice []int, a, b, k int) {
+			FloydRivestWithinFunc(slice, a, b, a+k, cmp.Less)
+		})
 	})
 }
 
@@ -306,6 +618,20 @@ func BenchmarkSelect(b *testing.B) {
 		{"FloydRivestSelect", func(data []int, k int) { FloydRivest(sort.IntSlice(data), k) }},
 		{"FloydRivestSelectOrdered", func(data []int, k int) { FloydRivestOrdered(data, k) }},
 		{"FloydRivestSelectFunc", func(data []int, k int) { FloydRivestFunc(data, k, cmp.Less) }},
+		{"PDQSelectCmpFunc", func(data []int, k int) { PDQSelectCmpFunc(data, k, cmp.Compare) }},
+		{"FloydRivestSelectCmpFunc", func(data []int, k int) { FloydRivestCmpFunc(data, k, cmp.Compare) }},
+		{"MedianOfMedians", func(data []int, k int) { MedianOfMedians(sort.IntSlice(data), k) }},
+		{"MedianOfMediansOrdered", func(data []int, k int) { MedianOfMediansOrdered(data, k) }},
+		{"MedianOfMediansFunc", func(data []int, k int) { MedianOfMediansFunc(data, k, cmp.Less) }},
+		{"PDQSelectWithFallbackOrdered", func(data []int, k int) {
+			PDQSelectWithFallbackOrdered(data, k, MedianOfMediansFallback)
+		}},
+		{"ParallelSelectOrdered", func(data []int, k int) { ParallelSelectOrdered(data, k, 0) }},
+		{"ParallelSelectFunc", func(data []int, k int) { ParallelSelectFunc(data, k, 0, cmp.Less) }},
+		{"Select", func(data []int, k int) { Select(sort.IntSlice(data), k) }},
+		{"SelectOrdered", func(data []int, k int) { SelectOrdered(data, k) }},
+		{"SelectFunc", func(data []int, k int) { SelectFunc(data, k, cmp.Less) }},
+		{"RadixSelect", func(data []int, k int) { RadixSelect(data, k) }},
 		// Partial sorting
 		{"PDQPartialSort", func(data []int, k int) {
 			PDQSelect(sort.IntSlice(data), k)
@@ -319,18 +645,13 @@ func BenchmarkSelect(b *testing.B) {
 			PDQSelectFunc(data, k, cmp.Less)
 			slices.SortFunc(data[:k], cmp.Compare)
 		}},
-		{"FloydRivestPartialSort", func(data []int, k int) {
-			FloydRivest(sort.IntSlice(data), k)
-			sort.Ints(data[:k])
-		}},
-		{"FloydRivestPartialSortOrdered", func(data []int, k int) {
-			FloydRivestOrdered(data, k)
-			slices.Sort(data[:k])
-		}},
-		{"FloydRivestPartialSortFunc", func(data []int, k int) {
-			FloydRivestFunc(data, k, cmp.Less)
-			slices.SortFunc(data[:k], cmp.Compare)
-		}},
+		{"FloydRivestPartialSort", func(data []int, k int) { FloydRivestPartialSort(sort.IntSlice(data), k) }},
+		{"FloydRivestPartialSortOrdered", func(data []int, k int) { FloydRivestPartialSortOrdered(data, k) }},
+		{"FloydRivestPartialSortFunc", func(data []int, k int) { FloydRivestPartialSortFunc(data, k, cmp.Less) }},
+		// Fused partial sorting
+		{"PartialSort", func(data []int, k int) { PartialSort(sort.IntSlice(data), k) }},
+		{"PartialSortOrdered", func(data []int, k int) { PartialSortOrdered(data, k) }},
+		{"PartialSortFunc", func(data []int, k int) { PartialSortFunc(data, k, cmp.Less) }},
 	}
 
 	// Main benchmark loops
@@ -353,11 +674,44 @@ func BenchmarkSelect(b *testing.B) {
 						}
 					})
 				}
+
+				// RadixSelect counts the values of 8-bit and 16-bit types rather than
+				// selecting byte by byte, so compare it with pdqselect on those too.
+				benchmarkNarrow[uint16](b, "uint16", data, k, dist, order)
+				benchmarkNarrow[uint8](b, "uint8", data, k, dist, order)
 			}
 		}
 	}
 }
 
+// benchmarkNarrow benchmarks PDQSelectOrdered and RadixSelect on data truncated to T.
+func benchmarkNarrow[T uint8 | uint16](b *testing.B, typ string, data []int, k int, dist Distribution, order Ordering) {
+	narrow := make([]T, len(data))
+	for i, x := range data {
+		narrow[i] = T(x)
+	}
+
+	for _, bc := range []struct {
+		name string
+		fn   func([]T, int)
+	}{
+		{"PDQSelectOrdered", PDQSelectOrdered[T]},
+		{"RadixSelect", RadixSelect[T]},
+	} {
+		name := fmt.Sprintf("fn=%s/type=%s/n=%d/k=%d/dist=%s/order=%s", bc.name, typ, len(data), k, dist, order)
+		b.Run(name, func(b *testing.B) {
+			dataCopy := make([]T, len(narrow))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				copy(dataCopy, narrow)
+				bc.fn(dataCopy, k)
+			}
+		})
+	}
+}
+
 func TestGenDistribution(t *testing.T) {
 	now := time.Now().UnixNano()
 	seeds := []uint64{uint64(now), uint64(now >> 32)}
@@ -0,0 +1,71 @@
+package kth
+
+import (
+	"cmp"
+	"math/bits"
+	"sort"
+)
+
+// SelectRange swaps elements in the data provided so that data[lo:hi] holds the elements
+// of rank lo, lo+1, ..., hi-1 (0-based) in sorted order, with every smaller element before
+// lo and every larger element at or after hi. This is what's needed to serve a page of
+// results ordered by some key without sorting the whole data set.
+//
+// The window is clamped to [0, n], and SelectRange does nothing if it ends up empty.
+//
+// It runs two bounded selection passes, one placing the element of rank lo and one placing
+// the element of rank hi-1 within data[lo:n], followed by a pdqsort of the window in between.
+func SelectRange(data sort.Interface, lo, hi int) {
+	n := data.Len()
+	lo, hi = max(lo, 0), min(hi, n)
+	if lo >= hi {
+		return
+	}
+	limit := bits.Len(uint(n))
+	if lo > 0 {
+		pdqselect(data, 0, n, lo, limit)
+		lo++ // data[lo] is now the minimum of data[lo:n].
+	}
+	if lo < hi {
+		pdqselect(data, lo, n, hi-1, limit)
+		pdqsort(data, lo, hi-1, bits.Len(uint(hi-1-lo)))
+	}
+}
+
+// SelectRangeOrdered is a specialized version of SelectRange that works with slices of
+// ordered types (i.e. types that implement the cmp.Ordered interface).
+func SelectRangeOrdered[T cmp.Ordered](data []T, lo, hi int) {
+	n := len(data)
+	lo, hi = max(lo, 0), min(hi, n)
+	if lo >= hi {
+		return
+	}
+	limit := bits.Len(uint(n))
+	if lo > 0 {
+		pdqselectOrdered(data, 0, n, lo, limit)
+		lo++ // data[lo] is now the minimum of data[lo:n].
+	}
+	if lo < hi {
+		pdqselectOrdered(data, lo, n, hi-1, limit)
+		pdqsortOrdered(data, lo, hi-1, bits.Len(uint(hi-1-lo)))
+	}
+}
+
+// SelectRangeFunc is a generic version of SelectRange that allows the caller to provide
+// a custom comparison function to determine the order of elements.
+func SelectRangeFunc[E any](data []E, lo, hi int, less func(a, b E) bool) {
+	n := len(data)
+	lo, hi = max(lo, 0), min(hi, n)
+	if lo >= hi {
+		return
+	}
+	limit := bits.Len(uint(n))
+	if lo > 0 {
+		pdqselectFunc(data, 0, n, lo, limit, less)
+		lo++ // data[lo] is now the minimum of data[lo:n].
+	}
+	if lo < hi {
+		pdqselectFunc(data, lo, n, hi-1, limit, less)
+		pdqsortLessFunc(data, lo, hi-1, bits.Len(uint(hi-1-lo)), less)
+	}
+}
@@ -0,0 +1,89 @@
+package kth
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestSelectRange(t *testing.T) {
+	rng := rand.New(rand.NewPCG(7, 8))
+
+	type rangeCase struct {
+		name   string
+		input  []int
+		lo, hi int
+	}
+
+	cases := []rangeCase{
+		{"Empty", []int{}, 0, 1},
+		{"Empty window", []int{3, 1, 2}, 2, 2},
+		{"Inverted window", []int{3, 1, 2}, 2, 1},
+		{"Clamped", []int{3, 1, 2, 5, 4}, -3, 10},
+		{"Single", []int{42}, 0, 1},
+		{"Last", []int{3, 1, 2, 5, 4}, 4, 5},
+		{"First", []int{3, 1, 2, 5, 4}, 0, 1},
+		{"All equal", []int{1, 1, 1, 1, 1}, 1, 4},
+	}
+
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist, BimodalDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder, MostlySorted, PushMiddleOrder} {
+			for _, size := range []int{13, 100, 5000} {
+				data := genDistribution(rng, size, dist)
+				applyOrdering(rng, data, order)
+				for range 4 {
+					lo := rng.IntN(size)
+					hi := lo + 1 + rng.IntN(min(size-lo, 50))
+					name := fmt.Sprintf("n=%d/lo=%d/hi=%d/dist=%s/order=%s", size, lo, hi, dist, order)
+					cases = append(cases, rangeCase{name, data, lo, hi})
+				}
+			}
+		}
+	}
+
+	funcs := []struct {
+		name string
+		fn   func([]int, int, int)
+	}{
+		{"SelectRange", func(data []int, lo, hi int) { SelectRange(sort.IntSlice(data), lo, hi) }},
+		{"SelectRangeOrdered", func(data []int, lo, hi int) { SelectRangeOrdered(data, lo, hi) }},
+		{"SelectRangeFunc", func(data []int, lo, hi int) { SelectRangeFunc(data, lo, hi, cmp.Less) }},
+	}
+
+	for _, tc := range cases {
+		for _, f := range funcs {
+			t.Run(f.name+"/"+tc.name, func(t *testing.T) {
+				sorted := slices.Clone(tc.input)
+				slices.Sort(sorted)
+
+				output := slices.Clone(tc.input)
+				f.fn(output, tc.lo, tc.hi)
+
+				lo, hi := max(tc.lo, 0), min(tc.hi, len(output))
+				if lo >= hi {
+					if !slices.Equal(output, tc.input) {
+						t.Fatalf("data was modified for an empty window: %v", output)
+					}
+					return
+				}
+
+				if !slices.Equal(output[lo:hi], sorted[lo:hi]) {
+					t.Fatalf("window does not hold the sorted ranks\nwant: %v\ngot:  %v", sorted[lo:hi], output[lo:hi])
+				}
+				for i := 0; i < lo; i++ {
+					if output[i] > output[lo] {
+						t.Fatalf("element at index %d (%d) is larger than the first element of the window (%d)", i, output[i], output[lo])
+					}
+				}
+				for i := hi; i < len(output); i++ {
+					if output[i] < output[hi-1] {
+						t.Fatalf("element at index %d (%d) is smaller than the last element of the window (%d)", i, output[i], output[hi-1])
+					}
+				}
+			})
+		}
+	}
+}
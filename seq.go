@@ -0,0 +1,140 @@
+//go:build go1.23
+
+package kth
+
+import (
+	"cmp"
+	"iter"
+)
+
+// TopKSeq returns the k largest elements of seq, sorted in decreasing order.
+// It keeps at most 2k elements in memory at any time, compacting them with the same
+// partitioning code as PDQSelectFunc, and runs in O(n + k log k) time. If seq yields
+// fewer than k elements, all of them are returned. If k < 1, it returns nil without
+// consuming seq.
+func TopKSeq[T cmp.Ordered](seq iter.Seq[T], k int) []T {
+	return BottomKSeqFunc(seq, k, greater[T])
+}
+
+// TopKSeqFunc is a generic version of TopKSeq that allows the caller to provide a custom
+// comparison function to determine the order of elements.
+func TopKSeqFunc[T any](seq iter.Seq[T], k int, less func(a, b T) bool) []T {
+	return BottomKSeqFunc(seq, k, func(a, b T) bool { return less(b, a) })
+}
+
+// BottomKSeq returns the k smallest elements of seq, sorted in increasing order.
+// It otherwise behaves like TopKSeq.
+func BottomKSeq[T cmp.Ordered](seq iter.Seq[T], k int) []T {
+	return BottomKSeqFunc(seq, k, cmp.Less[T])
+}
+
+// BottomKSeqFunc is a generic version of BottomKSeq that allows the caller to provide a
+// custom comparison function to determine the order of elements.
+func BottomKSeqFunc[T any](seq iter.Seq[T], k int, less func(a, b T) bool) []T {
+	if k < 1 {
+		return nil
+	}
+	topk := NewTopK(k, less)
+	for x := range seq {
+		topk.Push(x)
+	}
+	return topk.Sorted()
+}
+
+// KthSeq returns the k-th smallest element of seq, with k starting at 1 like in
+// PDQSelect, using O(k) memory. It returns false if k < 1 or seq yields fewer than
+// k elements.
+func KthSeq[T cmp.Ordered](seq iter.Seq[T], k int) (T, bool) {
+	return KthSeqFunc(seq, k, cmp.Less[T])
+}
+
+// KthSeqFunc is a generic version of KthSeq that allows the caller to provide a custom
+// comparison function to determine the order of elements.
+func KthSeqFunc[T any](seq iter.Seq[T], k int, less func(a, b T) bool) (T, bool) {
+	if k < 1 {
+		var zero T
+		return zero, false
+	}
+	topk := NewTopK(k, less)
+	for x := range seq {
+		topk.Push(x)
+	}
+	return topk.Threshold()
+}
+
+// TopKSeq2 returns an iterator over the k key-value pairs of seq with the largest values,
+// in decreasing order of value. The order of pairs with equal values is unspecified.
+// For instance, TopKSeq2(maps.All(counts), 10) yields the ten most frequent keys of a
+// map along with their counts. Like TopKSeq, it consumes seq before returning and keeps
+// O(k) pairs in memory.
+func TopKSeq2[K any, V cmp.Ordered](seq iter.Seq2[K, V], k int) iter.Seq2[K, V] {
+	return BottomKSeq2Func(seq, k, greater[V])
+}
+
+// TopKSeq2Func is a generic version of TopKSeq2 that allows the caller to provide a
+// custom comparison function to determine the order of values.
+func TopKSeq2Func[K, V any](seq iter.Seq2[K, V], k int, less func(a, b V) bool) iter.Seq2[K, V] {
+	return BottomKSeq2Func(seq, k, func(a, b V) bool { return less(b, a) })
+}
+
+// BottomKSeq2 returns an iterator over the k key-value pairs of seq with the smallest
+// values, in increasing order of value. It otherwise behaves like TopKSeq2.
+func BottomKSeq2[K any, V cmp.Ordered](seq iter.Seq2[K, V], k int) iter.Seq2[K, V] {
+	return BottomKSeq2Func(seq, k, cmp.Less[V])
+}
+
+// BottomKSeq2Func is a generic version of BottomKSeq2 that allows the caller to provide
+// a custom comparison function to determine the order of values.
+func BottomKSeq2Func[K, V any](seq iter.Seq2[K, V], k int, less func(a, b V) bool) iter.Seq2[K, V] {
+	var pairs []pair[K, V]
+	if k > 0 {
+		topk := NewTopK(k, pairLess[K](less))
+		for key, value := range seq {
+			topk.Push(pair[K, V]{key, value})
+		}
+		pairs = topk.Sorted()
+	}
+
+	return func(yield func(K, V) bool) {
+		for _, p := range pairs {
+			if !yield(p.key, p.value) {
+				return
+			}
+		}
+	}
+}
+
+// KthSeq2 returns the key-value pair of seq with the k-th smallest value, with k starting
+// at 1 like in PDQSelect, using O(k) memory. Which of several pairs with equal values is
+// returned is unspecified. It returns false if k < 1 or seq yields fewer than k pairs.
+func KthSeq2[K any, V cmp.Ordered](seq iter.Seq2[K, V], k int) (K, V, bool) {
+	return KthSeq2Func(seq, k, cmp.Less[V])
+}
+
+// KthSeq2Func is a generic version of KthSeq2 that allows the caller to provide a custom
+// comparison function to determine the order of values.
+func KthSeq2Func[K, V any](seq iter.Seq2[K, V], k int, less func(a, b V) bool) (K, V, bool) {
+	if k < 1 {
+		var p pair[K, V]
+		return p.key, p.value, false
+	}
+	topk := NewTopK(k, pairLess[K](less))
+	for key, value := range seq {
+		topk.Push(pair[K, V]{key, value})
+	}
+	p, ok := topk.Threshold()
+	return p.key, p.value, ok
+}
+
+type pair[K, V any] struct {
+	key   K
+	value V
+}
+
+func pairLess[K, V any](less func(a, b V) bool) func(a, b pair[K, V]) bool {
+	return func(a, b pair[K, V]) bool { return less(a.value, b.value) }
+}
+
+func greater[T cmp.Ordered](a, b T) bool {
+	return cmp.Less(b, a)
+}
@@ -0,0 +1,134 @@
+//go:build go1.23
+
+package kth
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestSeq(t *testing.T) {
+	rng := rand.New(rand.NewPCG(19, 20))
+
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder} {
+			for _, size := range []int{0, 1, 13, 1000} {
+				input := genDistribution(rng, max(size, 1), dist)[:size]
+				applyOrdering(rng, input, order)
+				sorted := slices.Clone(input)
+				slices.Sort(sorted)
+				reversed := slices.Clone(sorted)
+				slices.Reverse(reversed)
+
+				for _, k := range []int{0, 1, 7, size, size + 1} {
+					name := fmt.Sprintf("n=%d/k=%d/dist=%s/order=%s", size, k, dist, order)
+					t.Run(name, func(t *testing.T) {
+						n := min(max(k, 0), size)
+
+						for _, got := range [][]int{
+							BottomKSeq(slices.Values(input), k),
+							BottomKSeqFunc(slices.Values(input), k, cmp.Less),
+						} {
+							if !slices.Equal(got, sorted[:n]) {
+								t.Errorf("BottomKSeq = %v, want %v", got, sorted[:n])
+							}
+						}
+
+						for _, got := range [][]int{
+							TopKSeq(slices.Values(input), k),
+							TopKSeqFunc(slices.Values(input), k, cmp.Less),
+						} {
+							if !slices.Equal(got, reversed[:n]) {
+								t.Errorf("TopKSeq = %v, want %v", got, reversed[:n])
+							}
+						}
+
+						wantOK := k >= 1 && k <= size
+						for _, f := range []func() (int, bool){
+							func() (int, bool) { return KthSeq(slices.Values(input), k) },
+							func() (int, bool) { return KthSeqFunc(slices.Values(input), k, cmp.Less) },
+						} {
+							got, ok := f()
+							if ok != wantOK {
+								t.Errorf("KthSeq ok = %v, want %v", ok, wantOK)
+							} else if ok && got != sorted[k-1] {
+								t.Errorf("KthSeq = %d, want %d", got, sorted[k-1])
+							}
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestSeq2(t *testing.T) {
+	rng := rand.New(rand.NewPCG(21, 22))
+
+	counts := make(map[string]int)
+	for i := 0; i < 500; i++ {
+		counts[fmt.Sprint("key", i)] = rng.IntN(100)
+	}
+
+	values := slices.Sorted(maps.Values(counts))
+	reversed := slices.Clone(values)
+	slices.Reverse(reversed)
+
+	check := func(t *testing.T, seq func(yield func(string, int) bool), want []int) {
+		t.Helper()
+		var got []int
+		for key, value := range seq {
+			if counts[key] != value {
+				t.Fatalf("pair (%q, %d) does not match map value %d", key, value, counts[key])
+			}
+			got = append(got, value)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("got values %v, want %v", got, want)
+		}
+	}
+
+	for _, k := range []int{0, 1, 10, len(counts), len(counts) + 1} {
+		t.Run(fmt.Sprintf("k=%d", k), func(t *testing.T) {
+			n := min(k, len(counts))
+
+			check(t, TopKSeq2(maps.All(counts), k), reversed[:n])
+			check(t, TopKSeq2Func(maps.All(counts), k, cmp.Less), reversed[:n])
+			check(t, BottomKSeq2(maps.All(counts), k), values[:n])
+			check(t, BottomKSeq2Func(maps.All(counts), k, cmp.Less), values[:n])
+
+			wantOK := k >= 1 && k <= len(counts)
+			key, value, ok := KthSeq2(maps.All(counts), k)
+			if ok != wantOK {
+				t.Fatalf("KthSeq2 ok = %v, want %v", ok, wantOK)
+			}
+			if ok && (value != values[k-1] || counts[key] != value) {
+				t.Errorf("KthSeq2 = (%q, %d), want value %d", key, value, values[k-1])
+			}
+			key, value, ok = KthSeq2Func(maps.All(counts), k, cmp.Less)
+			if ok != wantOK {
+				t.Fatalf("KthSeq2Func ok = %v, want %v", ok, wantOK)
+			}
+			if ok && (value != values[k-1] || counts[key] != value) {
+				t.Errorf("KthSeq2Func = (%q, %d), want value %d", key, value, values[k-1])
+			}
+		})
+	}
+
+	t.Run("Early exit", func(t *testing.T) {
+		var got []int
+		for _, value := range TopKSeq2(maps.All(counts), 10) {
+			got = append(got, value)
+			if len(got) == 3 {
+				break
+			}
+		}
+		if !slices.Equal(got, reversed[:3]) {
+			t.Errorf("got %v, want %v", got, reversed[:3])
+		}
+	})
+}
@@ -0,0 +1,202 @@
+//go:build amd64 && !purego
+
+package kth
+
+import (
+	"cmp"
+	"reflect"
+	"unsafe"
+)
+
+// simdKernels is a set of partition kernels for the instruction set named, which move
+// the elements of data[readL:readR] smaller than p according to cmp.Less to data[left:],
+// and the others to data[:right], as long as data[readL:readR] holds a full vector.
+// Neither end may be shorter than a vector, so kernels that store full vectors can't
+// overwrite the elements that are yet to be read.
+type simdKernels struct {
+	name     string
+	vecBytes int
+
+	int32   func(data *int32, p int32, s *simdState)
+	int64   func(data *int64, p int64, s *simdState)
+	float32 func(data *float32, p float32, s *simdState)
+	float64 func(data *float64, p float64, s *simdState)
+}
+
+// simdState is where the partition kernels pick up and leave off: data[:left] holds
+// elements smaller than the pivot, data[right:] holds the others, data[readL:readR]
+// is yet to be read, and data[left:readL] and data[readR:right] are free.
+type simdState struct {
+	left, readL, readR, right int
+}
+
+var (
+	avx2Kernels = &simdKernels{
+		name:     "AVX2",
+		vecBytes: 32,
+		int32:    partitionAVX2Int32,
+		int64:    partitionAVX2Int64,
+		float32:  partitionAVX2Float32,
+		float64:  partitionAVX2Float64,
+	}
+
+	avx512Kernels = &simdKernels{
+		name:     "AVX-512",
+		vecBytes: 64,
+		int32:    partitionAVX512Int32,
+		int64:    partitionAVX512Int64,
+		float32:  partitionAVX512Float32,
+		float64:  partitionAVX512Float64,
+	}
+
+	// partitionKernels is the best set of kernels the CPU supports, if any.
+	partitionKernels *simdKernels
+
+	// avx2Perm32 and avx2Perm64 hold, for each mask of the lanes of a vector of 32-bit
+	// and 64-bit elements smaller than the pivot, the VPERMD indices that move those
+	// lanes to the front and the others to the back.
+	avx2Perm32 [256][8]uint32
+	avx2Perm64 [16][8]uint32
+)
+
+func init() {
+	for mask := range avx2Perm32 {
+		avx2Perm32[mask] = partitionPermutation(mask, 8, 1)
+	}
+	for mask := range avx2Perm64 {
+		avx2Perm64[mask] = partitionPermutation(mask, 4, 2)
+	}
+
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	const (
+		popcnt  = 1 << 23
+		osxsave = 1 << 27
+		avx     = 1 << 28
+	)
+	if ecx1&(popcnt|osxsave|avx) != popcnt|osxsave|avx {
+		return
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	xcr0, _ := xgetbv()
+
+	// The OS must save the YMM registers, and the opmask and ZMM registers for AVX-512.
+	if ebx7&(1<<5) != 0 && xcr0&0x06 == 0x06 {
+		partitionKernels = avx2Kernels
+	}
+	if ebx7&(1<<16) != 0 && xcr0&0xe6 == 0xe6 {
+		partitionKernels = avx512Kernels
+	}
+}
+
+// partitionPermutation returns the indices of the 32-bit words of a vector of lanes
+// elements, each words wide, that move the lanes set in mask to the front, in order,
+// followed by the others.
+func partitionPermutation(mask, lanes, words int) [8]uint32 {
+	var perm [8]uint32
+	i := 0
+	for _, set := range []bool{true, false} {
+		for lane := 0; lane < lanes; lane++ {
+			if mask&(1<<lane) != 0 == set {
+				for w := 0; w < words; w++ {
+					perm[i] = uint32(lane*words + w)
+					i++
+				}
+			}
+		}
+	}
+	return perm
+}
+
+// partitionSIMD moves the elements of data smaller than p according to cmp.Less before
+// the others, and returns how many there are, if the CPU has partition kernels for E.
+func partitionSIMD[E cmp.Ordered](data []E, p E) (int, bool) {
+	if partitionKernels == nil {
+		return 0, false
+	}
+	return partitionKernelsOrdered(partitionKernels, data, p)
+}
+
+// partitionKernelsOrdered is like partitionSIMD, but with the kernels given.
+func partitionKernelsOrdered[E cmp.Ordered](ks *simdKernels, data []E, p E) (int, bool) {
+	ptr := unsafe.Pointer(unsafe.SliceData(data))
+	pp := unsafe.Pointer(&p)
+	switch k := reflect.TypeFor[E]().Kind(); {
+	case k == reflect.Int32:
+		return partitionKernel(unsafe.Slice((*int32)(ptr), len(data)), *(*int32)(pp), ks.vecBytes/4, ks.int32), true
+	case k == reflect.Int64 || k == reflect.Int:
+		return partitionKernel(unsafe.Slice((*int64)(ptr), len(data)), *(*int64)(pp), ks.vecBytes/8, ks.int64), true
+	case k == reflect.Float32:
+		return partitionKernel(unsafe.Slice((*float32)(ptr), len(data)), *(*float32)(pp), ks.vecBytes/4, ks.float32), true
+	case k == reflect.Float64:
+		return partitionKernel(unsafe.Slice((*float64)(ptr), len(data)), *(*float64)(pp), ks.vecBytes/8, ks.float64), true
+	}
+	return 0, false
+}
+
+// partitionKernel runs kernel, which reads vectors of the given number of lanes, over
+// data. It first sets the first and last vectors aside to make room for the kernel's
+// stores, and then places them, along with whatever the kernel couldn't read in full
+// vectors, one element at a time.
+func partitionKernel[E int32 | int64 | float32 | float64](data []E, p E, lanes int, kernel func(*E, E, *simdState)) int {
+	n := len(data)
+	if p != p {
+		return 0 // Nothing is smaller than a NaN.
+	}
+	if n < 2*lanes {
+		return blockPartitionOrdered(data, p, true)
+	}
+
+	var buf [3 * 16]E
+	nb := copy(buf[:], data[:lanes])
+	nb += copy(buf[nb:], data[n-lanes:])
+
+	s := simdState{left: 0, readL: lanes, readR: n - lanes, right: n}
+	kernel(unsafe.SliceData(data), p, &s)
+	nb += copy(buf[nb:], data[s.readL:s.readR])
+
+	left, right := s.left, s.right
+	for _, x := range buf[:nb] {
+		if cmp.Less(x, p) {
+			data[left] = x
+			left++
+		} else {
+			right--
+			data[right] = x
+		}
+	}
+	return left
+}
+
+//go:noescape
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+//go:noescape
+func xgetbv() (eax, edx uint32)
+
+//go:noescape
+func partitionAVX2Int32(data *int32, p int32, s *simdState)
+
+//go:noescape
+func partitionAVX2Int64(data *int64, p int64, s *simdState)
+
+//go:noescape
+func partitionAVX2Float32(data *float32, p float32, s *simdState)
+
+//go:noescape
+func partitionAVX2Float64(data *float64, p float64, s *simdState)
+
+//go:noescape
+func partitionAVX512Int32(data *int32, p int32, s *simdState)
+
+//go:noescape
+func partitionAVX512Int64(data *int64, p int64, s *simdState)
+
+//go:noescape
+func partitionAVX512Float32(data *float32, p float32, s *simdState)
+
+//go:noescape
+func partitionAVX512Float64(data *float64, p float64, s *simdState)
@@ -0,0 +1,450 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL	eaxArg+0(FP), AX
+	MOVL	ecxArg+4(FP), CX
+	CPUID
+	MOVL	AX, eax+8(FP)
+	MOVL	BX, ebx+12(FP)
+	MOVL	CX, ecx+16(FP)
+	MOVL	DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL	$0, CX
+	XGETBV
+	MOVL	AX, eax+0(FP)
+	MOVL	DX, edx+4(FP)
+	RET
+
+// The partition kernels keep the fields of their simdState in R8 (left), R9 (readL),
+// R10 (readR) and R11 (right). Each iteration reads a vector from the end of
+// data[readL:readR] with the least free room next to it, which leaves room for a full
+// vector at both data[left:] and data[:right].
+
+#define LOAD_STATE \
+	MOVQ	0(SI), R8; \
+	MOVQ	8(SI), R9; \
+	MOVQ	16(SI), R10; \
+	MOVQ	24(SI), R11
+
+#define STORE_STATE \
+	MOVQ	R8, 0(SI); \
+	MOVQ	R9, 8(SI); \
+	MOVQ	R10, 16(SI); \
+	MOVQ	R11, 24(SI)
+
+// func partitionAVX2Int32(data *int32, p int32, s *simdState)
+TEXT ·partitionAVX2Int32(SB), NOSPLIT, $0-24
+	MOVQ	data+0(FP), DI
+	MOVL	p+8(FP), AX
+	VMOVD	AX, X0
+	VPBROADCASTD	X0, Y0
+	LEAQ	·avx2Perm32(SB), R12
+	MOVQ	s+16(FP), SI
+	LOAD_STATE
+
+loop:
+	MOVQ	R10, AX
+	SUBQ	R9, AX
+	CMPQ	AX, $8
+	JLT	done
+	MOVQ	R9, BX
+	SUBQ	R8, BX
+	MOVQ	R11, CX
+	SUBQ	R10, CX
+	CMPQ	BX, CX
+	JGT	right
+	VMOVDQU	(DI)(R9*4), Y1
+	ADDQ	$8, R9
+	JMP	partition
+
+right:
+	SUBQ	$8, R10
+	VMOVDQU	(DI)(R10*4), Y1
+
+partition:
+	// Move the lanes smaller than p to the front of the vector and the others to
+	// the back, and store it at both ends.
+	VPCMPGTD	Y1, Y0, Y2
+	VMOVMSKPS	Y2, AX
+	MOVQ	AX, BX
+	SHLQ	$5, BX
+	VMOVDQU	(R12)(BX*1), Y3
+	VPERMD	Y1, Y3, Y1
+	VMOVDQU	Y1, (DI)(R8*4)
+	VMOVDQU	Y1, -32(DI)(R11*4)
+	POPCNTL	AX, AX
+	ADDQ	AX, R8
+	SUBQ	$8, R11
+	ADDQ	AX, R11
+	JMP	loop
+
+done:
+	STORE_STATE
+	VZEROUPPER
+	RET
+
+// func partitionAVX2Int64(data *int64, p int64, s *simdState)
+TEXT ·partitionAVX2Int64(SB), NOSPLIT, $0-24
+	MOVQ	data+0(FP), DI
+	VPBROADCASTQ	p+8(FP), Y0
+	LEAQ	·avx2Perm64(SB), R12
+	MOVQ	s+16(FP), SI
+	LOAD_STATE
+
+loop:
+	MOVQ	R10, AX
+	SUBQ	R9, AX
+	CMPQ	AX, $4
+	JLT	done
+	MOVQ	R9, BX
+	SUBQ	R8, BX
+	MOVQ	R11, CX
+	SUBQ	R10, CX
+	CMPQ	BX, CX
+	JGT	right
+	VMOVDQU	(DI)(R9*8), Y1
+	ADDQ	$4, R9
+	JMP	partition
+
+right:
+	SUBQ	$4, R10
+	VMOVDQU	(DI)(R10*8), Y1
+
+partition:
+	// Move the lanes smaller than p to the front of the vector and the others to
+	// the back, and store it at both ends.
+	VPCMPGTQ	Y1, Y0, Y2
+	VMOVMSKPD	Y2, AX
+	MOVQ	AX, BX
+	SHLQ	$5, BX
+	VMOVDQU	(R12)(BX*1), Y3
+	VPERMD	Y1, Y3, Y1
+	VMOVDQU	Y1, (DI)(R8*8)
+	VMOVDQU	Y1, -32(DI)(R11*8)
+	POPCNTL	AX, AX
+	ADDQ	AX, R8
+	SUBQ	$4, R11
+	ADDQ	AX, R11
+	JMP	loop
+
+done:
+	STORE_STATE
+	VZEROUPPER
+	RET
+
+// func partitionAVX2Float32(data *float32, p float32, s *simdState)
+TEXT ·partitionAVX2Float32(SB), NOSPLIT, $0-24
+	MOVQ	data+0(FP), DI
+	VBROADCASTSS	p+8(FP), Y0
+	LEAQ	·avx2Perm32(SB), R12
+	MOVQ	s+16(FP), SI
+	LOAD_STATE
+
+loop:
+	MOVQ	R10, AX
+	SUBQ	R9, AX
+	CMPQ	AX, $8
+	JLT	done
+	MOVQ	R9, BX
+	SUBQ	R8, BX
+	MOVQ	R11, CX
+	SUBQ	R10, CX
+	CMPQ	BX, CX
+	JGT	right
+	VMOVDQU	(DI)(R9*4), Y1
+	ADDQ	$8, R9
+	JMP	partition
+
+right:
+	SUBQ	$8, R10
+	VMOVDQU	(DI)(R10*4), Y1
+
+partition:
+	// Move the lanes smaller than p to the front of the vector and the others to
+	// the back, and store it at both ends.
+	VCMPPS	$0x19, Y0, Y1, Y2
+	VMOVMSKPS	Y2, AX
+	MOVQ	AX, BX
+	SHLQ	$5, BX
+	VMOVDQU	(R12)(BX*1), Y3
+	VPERMD	Y1, Y3, Y1
+	VMOVDQU	Y1, (DI)(R8*4)
+	VMOVDQU	Y1, -32(DI)(R11*4)
+	POPCNTL	AX, AX
+	ADDQ	AX, R8
+	SUBQ	$8, R11
+	ADDQ	AX, R11
+	JMP	loop
+
+done:
+	STORE_STATE
+	VZEROUPPER
+	RET
+
+// func partitionAVX2Float64(data *float64, p float64, s *simdState)
+TEXT ·partitionAVX2Float64(SB), NOSPLIT, $0-24
+	MOVQ	data+0(FP), DI
+	VBROADCASTSD	p+8(FP), Y0
+	LEAQ	·avx2Perm64(SB), R12
+	MOVQ	s+16(FP), SI
+	LOAD_STATE
+
+loop:
+	MOVQ	R10, AX
+	SUBQ	R9, AX
+	CMPQ	AX, $4
+	JLT	done
+	MOVQ	R9, BX
+	SUBQ	R8, BX
+	MOVQ	R11, CX
+	SUBQ	R10, CX
+	CMPQ	BX, CX
+	JGT	right
+	VMOVDQU	(DI)(R9*8), Y1
+	ADDQ	$4, R9
+	JMP	partition
+
+right:
+	SUBQ	$4, R10
+	VMOVDQU	(DI)(R10*8), Y1
+
+partition:
+	// Move the lanes smaller than p to the front of the vector and the others to
+	// the back, and store it at both ends.
+	VCMPPD	$0x19, Y0, Y1, Y2
+	VMOVMSKPD	Y2, AX
+	MOVQ	AX, BX
+	SHLQ	$5, BX
+	VMOVDQU	(R12)(BX*1), Y3
+	VPERMD	Y1, Y3, Y1
+	VMOVDQU	Y1, (DI)(R8*8)
+	VMOVDQU	Y1, -32(DI)(R11*8)
+	POPCNTL	AX, AX
+	ADDQ	AX, R8
+	SUBQ	$4, R11
+	ADDQ	AX, R11
+	JMP	loop
+
+done:
+	STORE_STATE
+	VZEROUPPER
+	RET
+
+// func partitionAVX512Int32(data *int32, p int32, s *simdState)
+TEXT ·partitionAVX512Int32(SB), NOSPLIT, $0-24
+	MOVQ	data+0(FP), DI
+	MOVL	p+8(FP), AX
+	VPBROADCASTD	AX, Z0
+	MOVQ	s+16(FP), SI
+	LOAD_STATE
+
+loop:
+	MOVQ	R10, AX
+	SUBQ	R9, AX
+	CMPQ	AX, $16
+	JLT	done
+	MOVQ	R9, BX
+	SUBQ	R8, BX
+	MOVQ	R11, CX
+	SUBQ	R10, CX
+	CMPQ	BX, CX
+	JGT	right
+	VMOVDQU32	(DI)(R9*4), Z1
+	ADDQ	$16, R9
+	JMP	partition
+
+right:
+	SUBQ	$16, R10
+	VMOVDQU32	(DI)(R10*4), Z1
+
+partition:
+	// Compress the lanes smaller than p to the front of one vector and the others
+	// to the front of another. The former is stored in full at data[left:], but the
+	// latter must end at data[right], so only its valid lanes are stored.
+	VPCMPD	$1, Z0, Z1, K1
+	KNOTW	K1, K2
+	VPCOMPRESSD	Z1, K1, Z2
+	VPCOMPRESSD	Z1, K2, Z3
+	KMOVW	K1, AX
+	POPCNTL	AX, AX
+	VMOVDQU32	Z2, (DI)(R8*4)
+	ADDQ	AX, R8
+	MOVL	$16, CX
+	SUBL	AX, CX
+	SUBQ	CX, R11
+	MOVL	$1, BX
+	SHLL	CX, BX
+	DECL	BX
+	KMOVW	BX, K3
+	VMOVDQU32	Z3, K3, (DI)(R11*4)
+	JMP	loop
+
+done:
+	STORE_STATE
+	VZEROUPPER
+	RET
+
+// func partitionAVX512Int64(data *int64, p int64, s *simdState)
+TEXT ·partitionAVX512Int64(SB), NOSPLIT, $0-24
+	MOVQ	data+0(FP), DI
+	VPBROADCASTQ	p+8(FP), Z0
+	MOVQ	s+16(FP), SI
+	LOAD_STATE
+
+loop:
+	MOVQ	R10, AX
+	SUBQ	R9, AX
+	CMPQ	AX, $8
+	JLT	done
+	MOVQ	R9, BX
+	SUBQ	R8, BX
+	MOVQ	R11, CX
+	SUBQ	R10, CX
+	CMPQ	BX, CX
+	JGT	right
+	VMOVDQU64	(DI)(R9*8), Z1
+	ADDQ	$8, R9
+	JMP	partition
+
+right:
+	SUBQ	$8, R10
+	VMOVDQU64	(DI)(R10*8), Z1
+
+partition:
+	// Compress the lanes smaller than p to the front of one vector and the others
+	// to the front of another. The former is stored in full at data[left:], but the
+	// latter must end at data[right], so only its valid lanes are stored.
+	VPCMPQ	$1, Z0, Z1, K1
+	KNOTW	K1, K2
+	VPCOMPRESSQ	Z1, K1, Z2
+	VPCOMPRESSQ	Z1, K2, Z3
+	KMOVW	K1, AX
+	POPCNTL	AX, AX
+	VMOVDQU64	Z2, (DI)(R8*8)
+	ADDQ	AX, R8
+	MOVL	$8, CX
+	SUBL	AX, CX
+	SUBQ	CX, R11
+	MOVL	$1, BX
+	SHLL	CX, BX
+	DECL	BX
+	KMOVW	BX, K3
+	VMOVDQU64	Z3, K3, (DI)(R11*8)
+	JMP	loop
+
+done:
+	STORE_STATE
+	VZEROUPPER
+	RET
+
+// func partitionAVX512Float32(data *float32, p float32, s *simdState)
+TEXT ·partitionAVX512Float32(SB), NOSPLIT, $0-24
+	MOVQ	data+0(FP), DI
+	VBROADCASTSS	p+8(FP), Z0
+	MOVQ	s+16(FP), SI
+	LOAD_STATE
+
+loop:
+	MOVQ	R10, AX
+	SUBQ	R9, AX
+	CMPQ	AX, $16
+	JLT	done
+	MOVQ	R9, BX
+	SUBQ	R8, BX
+	MOVQ	R11, CX
+	SUBQ	R10, CX
+	CMPQ	BX, CX
+	JGT	right
+	VMOVUPS	(DI)(R9*4), Z1
+	ADDQ	$16, R9
+	JMP	partition
+
+right:
+	SUBQ	$16, R10
+	VMOVUPS	(DI)(R10*4), Z1
+
+partition:
+	// Compress the lanes smaller than p to the front of one vector and the others
+	// to the front of another. The former is stored in full at data[left:], but the
+	// latter must end at data[right], so only its valid lanes are stored.
+	VCMPPS	$0x19, Z0, Z1, K1
+	KNOTW	K1, K2
+	VCOMPRESSPS	Z1, K1, Z2
+	VCOMPRESSPS	Z1, K2, Z3
+	KMOVW	K1, AX
+	POPCNTL	AX, AX
+	VMOVUPS	Z2, (DI)(R8*4)
+	ADDQ	AX, R8
+	MOVL	$16, CX
+	SUBL	AX, CX
+	SUBQ	CX, R11
+	MOVL	$1, BX
+	SHLL	CX, BX
+	DECL	BX
+	KMOVW	BX, K3
+	VMOVUPS	Z3, K3, (DI)(R11*4)
+	JMP	loop
+
+done:
+	STORE_STATE
+	VZEROUPPER
+	RET
+
+// func partitionAVX512Float64(data *float64, p float64, s *simdState)
+TEXT ·partitionAVX512Float64(SB), NOSPLIT, $0-24
+	MOVQ	data+0(FP), DI
+	VBROADCASTSD	p+8(FP), Z0
+	MOVQ	s+16(FP), SI
+	LOAD_STATE
+
+loop:
+	MOVQ	R10, AX
+	SUBQ	R9, AX
+	CMPQ	AX, $8
+	JLT	done
+	MOVQ	R9, BX
+	SUBQ	R8, BX
+	MOVQ	R11, CX
+	SUBQ	R10, CX
+	CMPQ	BX, CX
+	JGT	right
+	VMOVUPD	(DI)(R9*8), Z1
+	ADDQ	$8, R9
+	JMP	partition
+
+right:
+	SUBQ	$8, R10
+	VMOVUPD	(DI)(R10*8), Z1
+
+partition:
+	// Compress the lanes smaller than p to the front of one vector and the others
+	// to the front of another. The former is stored in full at data[left:], but the
+	// latter must end at data[right], so only its valid lanes are stored.
+	VCMPPD	$0x19, Z0, Z1, K1
+	KNOTW	K1, K2
+	VCOMPRESSPD	Z1, K1, Z2
+	VCOMPRESSPD	Z1, K2, Z3
+	KMOVW	K1, AX
+	POPCNTL	AX, AX
+	VMOVUPD	Z2, (DI)(R8*8)
+	ADDQ	AX, R8
+	MOVL	$8, CX
+	SUBL	AX, CX
+	SUBQ	CX, R11
+	MOVL	$1, BX
+	SHLL	CX, BX
+	DECL	BX
+	KMOVW	BX, K3
+	VMOVUPD	Z3, K3, (DI)(R11*8)
+	JMP	loop
+
+done:
+	STORE_STATE
+	VZEROUPPER
+	RET
@@ -0,0 +1,162 @@
+//go:build amd64 && !purego
+
+package kth
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// availableKernels returns the kernel sets the CPU supports, from the oldest
+// instruction set to the newest.
+func availableKernels() []*simdKernels {
+	var ks []*simdKernels
+	for _, k := range []*simdKernels{avx2Kernels, avx512Kernels} {
+		if k == partitionKernels || k == avx2Kernels && partitionKernels == avx512Kernels {
+			ks = append(ks, k)
+		}
+	}
+	return ks
+}
+
+func TestPartitionSIMD(t *testing.T) {
+	ks := availableKernels()
+	if len(ks) == 0 {
+		t.Skip("no partition kernels for this CPU")
+	}
+	rng := rand.New(rand.NewPCG(39, 40))
+
+	for _, k := range ks {
+		for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist, BimodalDist} {
+			for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder} {
+				for _, size := range []int{0, 1, 7, 8, 16, 17, 33, 100, 1000, 5000} {
+					input := genDistribution(rng, size, dist)
+					applyOrdering(rng, input, order)
+
+					name := fmt.Sprintf("%s/n=%d/dist=%s/order=%s", k.name, size, dist, order)
+					t.Run(name, func(t *testing.T) {
+						for _, pivot := range []int{0, size / 3, size - 1} {
+							if pivot < 0 {
+								continue
+							}
+							testPartitionSIMD(t, k, input, pivot, func(x int) int { return x })
+							testPartitionSIMD(t, k, input, pivot, func(x int) int32 { return int32(x - size/2) })
+							testPartitionSIMD(t, k, input, pivot, func(x int) int64 { return int64(x) << 40 })
+							testPartitionSIMD(t, k, input, pivot, func(x int) float32 { return float32(x-size/2) / 3 })
+							testPartitionSIMD(t, k, input, pivot, func(x int) float64 { return float64(x) * 1e300 })
+						}
+					})
+				}
+			}
+		}
+
+		t.Run(k.name+"/Floats", func(t *testing.T) {
+			specials := []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1), 0}
+			input := make([]int, 1000)
+			for i := range input {
+				input[i] = i
+			}
+			toFloat := func(x int) float64 {
+				if x%4 == 0 {
+					return specials[x/4%len(specials)]
+				}
+				return float64(x%100) - 50
+			}
+			for pivot := range 20 {
+				testPartitionSIMD(t, k, input, pivot, toFloat)
+				testPartitionSIMD(t, k, input, pivot, func(x int) float32 { return float32(toFloat(x)) })
+			}
+		})
+	}
+
+	t.Run("Selection", func(t *testing.T) {
+		defer func(ks *simdKernels) { partitionKernels = ks }(partitionKernels)
+
+		for _, k := range append(ks, nil) {
+			partitionKernels = k
+			for _, size := range []int{3000, 100000} {
+				input := genDistribution(rng, size, UniformDist)
+				applyOrdering(rng, input, RandomOrder)
+				testBlockPartition(t, input, size/2)
+				for _, k := range []int{1, size / 2, size} {
+					testSelect(t, input, 0, size, k, "pdqselect", func(data []int, a, b, k int) {
+						pdqselectOrdered(data, a, b, k-1, bits.Len(uint(b-a)))
+					})
+				}
+			}
+		}
+	})
+}
+
+func FuzzPartitionSIMD(f *testing.F) {
+	f.Add(encodeFloats(1, math.NaN(), 2, 0, math.Copysign(0, -1), 1, 4, 2, 1, 3, 3, 3, 3, 3, 3, 3, 3), uint16(3))
+	f.Add(encodeFloats(5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5), uint16(0))
+
+	f.Fuzz(func(t *testing.T, data []byte, pivot uint16) {
+		input := decodeFloats(data)
+		if len(input) == 0 {
+			return
+		}
+		ints := make([]int, len(input))
+		for i := range ints {
+			ints[i] = i
+		}
+		for _, k := range availableKernels() {
+			testPartitionSIMD(t, k, ints, int(pivot)%len(input), func(i int) float64 { return input[i] })
+			testPartitionSIMD(t, k, ints, int(pivot)%len(input), func(i int) float32 { return float32(input[i]) })
+			testPartitionSIMD(t, k, ints, int(pivot)%len(input), func(i int) int64 { return int64(input[i]) })
+			testPartitionSIMD(t, k, ints, int(pivot)%len(input), func(i int) int32 { return int32(input[i]) })
+		}
+	})
+}
+
+// testPartitionSIMD checks the kernels given against cmp.Less, partitioning input,
+// converted to E, around the element at index pivot.
+func testPartitionSIMD[E int | int32 | int64 | float32 | float64](t *testing.T, ks *simdKernels, input []int, pivot int, conv func(int) E) {
+	t.Helper()
+
+	data := make([]E, len(input))
+	for i, x := range input {
+		data[i] = conv(x)
+	}
+	var p E
+	if len(data) > 0 {
+		p = data[pivot]
+	}
+	want := 0
+	for _, x := range data {
+		want += b2i(cmp.Less(x, p))
+	}
+
+	got := slices.Clone(data)
+	m, ok := partitionKernelsOrdered(ks, got, p)
+	if !ok {
+		t.Fatalf("%s: no kernel for %T", ks.name, data)
+	}
+	if m != want {
+		t.Fatalf("%s: %T: partition = %d, want %d", ks.name, data, m, want)
+	}
+	for i, x := range got {
+		if i < m != cmp.Less(x, p) {
+			t.Fatalf("%s: %T: element %v at index %d is on the wrong side of %v at %d", ks.name, data, x, i, p, m)
+		}
+	}
+
+	// Compare bit patterns, which tells -0 from +0 and keeps NaNs equal to themselves.
+	bits := func(s []E) []string {
+		b := make([]string, len(s))
+		for i, x := range s {
+			b[i] = fmt.Sprintf("%b", x)
+		}
+		slices.Sort(b)
+		return b
+	}
+	if !slices.Equal(bits(got), bits(data)) {
+		t.Fatalf("%s: %T: data is not a permutation of the input", ks.name, data)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !amd64 || purego
+
+package kth
+
+import "cmp"
+
+// partitionSIMD moves the elements of data smaller than p according to cmp.Less before
+// the others, and returns how many there are, if the CPU has partition kernels for E.
+// There are none on this platform.
+func partitionSIMD[E cmp.Ordered](data []E, p E) (int, bool) {
+	return 0, false
+}
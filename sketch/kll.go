@@ -0,0 +1,320 @@
+package sketch
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand/v2"
+
+	"github.com/tsenart/kth"
+)
+
+// DefaultKLLSize is the size parameter NewKLL uses when given one that isn't positive.
+const DefaultKLLSize = 200
+
+// KLL is the quantile sketch of Karnin, Lang and Liberty, from "Optimal Quantile
+// Approximation in Streams", FOCS 2016.
+//
+// It keeps values in a hierarchy of compactors, where each value of level h stands for
+// 2^h values of the stream. When a level fills up, it's sorted and every other value,
+// starting from a random one, is promoted to the level above, the rest being dropped.
+// Lower levels hold fewer values than the ones above, by a factor of 2/3 each, down to 2.
+//
+// With size parameter k, a KLL keeps about 3k values, and the rank of the value its
+// Quantile returns for q is off by a fraction of the number of values added that
+// shrinks roughly as 1/k, however many there are: within about 1.3% with the default
+// of 200, with 99% probability. Rank and CDF have the same error.
+//
+// The zero value is an empty KLL with the default size parameter.
+type KLL struct {
+	k        int
+	n        uint64
+	min, max float64
+	levels   [][]float64 // levels[h] holds values of weight 1<<h
+	rng      *rand.Rand
+}
+
+// NewKLL returns an empty KLL with size parameter k, or DefaultKLLSize if k < 1.
+// It panics if k isn't within [8, 65535], as smaller sketches are useless and larger
+// ones wouldn't save memory over keeping the values.
+func NewKLL(k int) *KLL {
+	if k < 1 {
+		k = DefaultKLLSize
+	}
+	if k < 8 || k > math.MaxUint16 {
+		panic("sketch: KLL size out of range")
+	}
+	return &KLL{k: k}
+}
+
+// init gives the zero value its default size parameter, and s its source of coin flips.
+func (s *KLL) init() {
+	if s.k == 0 {
+		s.k = DefaultKLLSize
+	}
+	if s.rng == nil {
+		s.rng = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+}
+
+// Count returns the number of values added to s, including through Merge.
+func (s *KLL) Count() uint64 {
+	return s.n
+}
+
+// Add adds x to the stream s summarizes. NaNs are ignored.
+func (s *KLL) Add(x float64) {
+	if x != x {
+		return
+	}
+	s.init()
+	if s.n == 0 {
+		s.min, s.max = x, x
+	} else {
+		s.min, s.max = min(s.min, x), max(s.max, x)
+	}
+	s.n++
+
+	if len(s.levels) == 0 {
+		s.levels = append(s.levels, make([]float64, 0, s.capacity(0)))
+	}
+	s.levels[0] = append(s.levels[0], x)
+	s.compress()
+}
+
+// Merge adds all values summarized by o to s, as if they had been added to s directly,
+// leaving o untouched. It returns an error if o has a different size parameter.
+func (s *KLL) Merge(o *KLL) error {
+	s.init()
+	if o.k != s.k && (o.k != 0 || s.k != DefaultKLLSize) {
+		return errors.New("sketch: can't merge KLL sketches of different sizes")
+	}
+	if o.n == 0 {
+		return nil
+	}
+	if s.n == 0 {
+		s.min, s.max = o.min, o.max
+	} else {
+		s.min, s.max = min(s.min, o.min), max(s.max, o.max)
+	}
+	s.n += o.n
+
+	for h, level := range o.levels {
+		if h == len(s.levels) {
+			s.levels = append(s.levels, nil)
+		}
+		s.levels[h] = append(s.levels[h], level...)
+	}
+	s.compress()
+	return nil
+}
+
+// capacity returns the number of values level h can hold before it's compacted.
+func (s *KLL) capacity(h int) int {
+	depth := max(len(s.levels)-1-h, 0)
+	return max(int(float64(s.k)*math.Pow(2.0/3, float64(depth))), 2)
+}
+
+// compress compacts the lowest level that's full until s holds no more values than
+// the capacities of its levels add up to. Only compacting when the sketch as a whole
+// is full, rather than each level, lets lower levels absorb more values before they
+// are compacted, which makes for smaller errors.
+func (s *KLL) compress() {
+	for {
+		size, capacity := 0, 0
+		for h, level := range s.levels {
+			size += len(level)
+			capacity += s.capacity(h)
+		}
+		if size <= capacity {
+			return
+		}
+		for h, level := range s.levels {
+			if len(level) >= s.capacity(h) {
+				s.compact(h)
+				break
+			}
+		}
+	}
+}
+
+// compact promotes every other value of level h, in sorted order, to level h+1, and
+// drops the others. If the level holds an odd number of values, its smallest stays.
+func (s *KLL) compact(h int) {
+	if h+1 == len(s.levels) {
+		s.levels = append(s.levels, nil)
+	}
+	level := s.levels[h]
+	// Pairing up neighbours takes the whole level in order, so kth's fused partial sort
+	// runs over all of it.
+	kth.PartialSortOrdered(level, len(level))
+
+	start := len(level) % 2
+	for i := start + int(s.rng.Uint64()&1); i < len(level); i += 2 {
+		s.levels[h+1] = append(s.levels[h+1], level[i])
+	}
+	s.levels[h] = level[:start]
+}
+
+// values returns the values s holds along with their weights.
+func (s *KLL) values() ([]float64, []uint64) {
+	size := 0
+	for _, level := range s.levels {
+		size += len(level)
+	}
+	values := make([]float64, 0, size)
+	weights := make([]uint64, 0, size)
+	for h, level := range s.levels {
+		values = append(values, level...)
+		for range level {
+			weights = append(weights, 1<<h)
+		}
+	}
+	return values, weights
+}
+
+// Quantile returns an estimate of the q-th quantile of the values added to s, for q
+// in [0, 1], which is the smallest value whose estimated rank reaches q times their
+// count. It returns the smallest and largest values added for 0 and 1, and NaN if s
+// is empty or q is out of range.
+func (s *KLL) Quantile(q float64) float64 {
+	if s.n == 0 || !(q >= 0 && q <= 1) {
+		return math.NaN()
+	}
+	switch q {
+	case 0:
+		return s.min
+	case 1:
+		return s.max
+	}
+	values, weights := s.values()
+	return kth.WeightedQuantile(values, weights, q)
+}
+
+// Rank returns an estimate of the number of values added to s that are less than or
+// equal to x.
+func (s *KLL) Rank(x float64) float64 {
+	switch {
+	case s.n == 0 || x < s.min:
+		return 0
+	case x >= s.max:
+		return float64(s.n)
+	}
+	var rank uint64
+	for h, level := range s.levels {
+		for _, y := range level {
+			if y <= x {
+				rank += 1 << h
+			}
+		}
+	}
+	return float64(rank)
+}
+
+// CDF returns an estimate of the fraction of the values added to s that are less than
+// or equal to x. It returns NaN if s is empty.
+func (s *KLL) CDF(x float64) float64 {
+	if s.n == 0 {
+		return math.NaN()
+	}
+	return s.Rank(x) / float64(s.n)
+}
+
+// kllMagic starts the binary format of a KLL.
+const kllMagic = 'K'
+
+// MarshalBinary encodes s in a compact binary format.
+func (s *KLL) MarshalBinary() ([]byte, error) {
+	s.init()
+	b := []byte{kllMagic, formatVersion}
+	b = binary.AppendUvarint(b, uint64(s.k))
+	b = binary.AppendUvarint(b, s.n)
+	b = appendFloat(b, s.min)
+	b = appendFloat(b, s.max)
+	b = binary.AppendUvarint(b, uint64(len(s.levels)))
+	for _, level := range s.levels {
+		b = binary.AppendUvarint(b, uint64(len(level)))
+		for _, x := range level {
+			b = appendFloat(b, x)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a KLL encoded by MarshalBinary into s, replacing its contents.
+func (s *KLL) UnmarshalBinary(b []byte) error {
+	d := decoder{b: b}
+	d.header(kllMagic)
+	var v kllJSON
+	v.K = int(d.uvarint())
+	v.N = d.uvarint()
+	v.Min, v.Max = d.f64(), d.f64()
+	v.Levels = make([][]float64, d.length(1))
+	for h := range v.Levels {
+		v.Levels[h] = make([]float64, d.length(8))
+		for i := range v.Levels[h] {
+			v.Levels[h][i] = d.f64()
+		}
+	}
+	if err := d.done(); err != nil {
+		return err
+	}
+	return s.set(v)
+}
+
+// kllJSON is the JSON representation of a KLL.
+type kllJSON struct {
+	K      int         `json:"k"`
+	N      uint64      `json:"n"`
+	Min    float64     `json:"min"`
+	Max    float64     `json:"max"`
+	Levels [][]float64 `json:"levels"`
+}
+
+// MarshalJSON encodes s as a JSON object. It fails if s holds infinities, which JSON
+// can't represent.
+func (s *KLL) MarshalJSON() ([]byte, error) {
+	s.init()
+	levels := s.levels
+	if levels == nil {
+		levels = [][]float64{}
+	}
+	return json.Marshal(kllJSON{K: s.k, N: s.n, Min: s.min, Max: s.max, Levels: levels})
+}
+
+// UnmarshalJSON decodes a KLL encoded by MarshalJSON into s, replacing its contents.
+func (s *KLL) UnmarshalJSON(b []byte) error {
+	var v kllJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return s.set(v)
+}
+
+// set replaces the contents of s with v, checking that they are consistent.
+func (s *KLL) set(v kllJSON) error {
+	if v.K < 8 || v.K > math.MaxUint16 {
+		return ErrCorrupt
+	}
+	var n uint64
+	for h, level := range v.Levels {
+		if h >= 64 {
+			return ErrCorrupt
+		}
+		for _, x := range level {
+			if x != x || x < v.Min || x > v.Max {
+				return ErrCorrupt
+			}
+		}
+		n += uint64(len(level)) << h
+	}
+	if n != v.N {
+		return ErrCorrupt
+	}
+
+	*s = KLL{k: v.K, n: v.N, min: v.Min, max: v.Max, levels: v.Levels, rng: s.rng}
+	s.init()
+	s.compress()
+	return nil
+}
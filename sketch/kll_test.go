@@ -0,0 +1,207 @@
+package sketch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/tsenart/kth"
+)
+
+// testQuantiles are the quantiles the sketches are checked at.
+var testQuantiles = []float64{0.001, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999}
+
+// genStream returns n values drawn from the named distribution.
+func genStream(rng *rand.Rand, n int, dist string) []float64 {
+	data := make([]float64, n)
+	for i := range data {
+		switch dist {
+		case "uniform":
+			data[i] = rng.Float64()
+		case "normal":
+			data[i] = rng.NormFloat64()
+		case "exponential":
+			data[i] = rng.ExpFloat64()
+		case "lognormal":
+			data[i] = math.Exp(2 * rng.NormFloat64())
+		case "discrete":
+			data[i] = float64(rng.IntN(20))
+		default:
+			panic("unknown distribution " + dist)
+		}
+	}
+	return data
+}
+
+var testDistributions = []string{"uniform", "normal", "exponential", "lognormal", "discrete"}
+
+// exactQuantile returns the element of rank ⌈qn⌉ of data, selected with kth.
+func exactQuantile(data []float64, q float64) float64 {
+	k := max(int(math.Ceil(q*float64(len(data)))), 1)
+	data = slices.Clone(data)
+	kth.PDQSelectOrdered(data, k)
+	return data[k-1]
+}
+
+// rankError returns how far, as a fraction of len(sorted), the ranks of x within sorted
+// are from q.
+func rankError(sorted []float64, x, q float64) float64 {
+	n := float64(len(sorted))
+	lo := float64(sort.SearchFloat64s(sorted, x))                                      // elements < x
+	hi := float64(sort.Search(len(sorted), func(i int) bool { return sorted[i] > x })) // elements <= x
+	switch t := q * n; {
+	case t < lo:
+		return (lo - t) / n
+	case t > hi:
+		return (t - hi) / n
+	default:
+		return 0
+	}
+}
+
+func TestKLL(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	for _, dist := range testDistributions {
+		data := genStream(rng, 200000, dist)
+		sorted := slices.Clone(data)
+		slices.Sort(sorted)
+
+		t.Run(dist, func(t *testing.T) {
+			s := NewKLL(0)
+			for _, x := range data {
+				s.Add(x)
+			}
+			checkKLL(t, s, data, sorted)
+
+			// Merging sketches of parts of the stream is as good as sketching all of it.
+			merged := NewKLL(0)
+			for i := 0; i < len(data); i += 20000 {
+				p := NewKLL(0)
+				for _, x := range data[i:min(i+20000, len(data))] {
+					p.Add(x)
+				}
+				if err := merged.Merge(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			checkKLL(t, merged, data, sorted)
+		})
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		var s KLL
+		if q, r, c := s.Quantile(0.5), s.Rank(1), s.CDF(1); !math.IsNaN(q) || r != 0 || !math.IsNaN(c) {
+			t.Fatalf("Quantile, Rank, CDF = %v, %v, %v, want NaN, 0, NaN", q, r, c)
+		}
+		s.Add(math.NaN())
+		if s.Count() != 0 {
+			t.Fatalf("Count = %d after adding NaN, want 0", s.Count())
+		}
+		s.Add(3)
+		if q := s.Quantile(0.5); q != 3 {
+			t.Fatalf("Quantile(0.5) = %v, want 3", q)
+		}
+	})
+
+	t.Run("Incompatible", func(t *testing.T) {
+		if err := NewKLL(100).Merge(NewKLL(200)); err == nil {
+			t.Fatal("Merge of different sizes succeeded")
+		}
+	})
+}
+
+// checkKLL checks the answers of s against the exact ones for data.
+func checkKLL(t *testing.T, s *KLL, data, sorted []float64) {
+	t.Helper()
+
+	if s.Count() != uint64(len(data)) {
+		t.Fatalf("Count = %d, want %d", s.Count(), len(data))
+	}
+	// The error is within 1.3% with 99% probability, and within twice that for sure.
+	const eps = 0.026
+	for _, q := range testQuantiles {
+		got := s.Quantile(q)
+		if e := rankError(sorted, got, q); e > eps {
+			t.Errorf("Quantile(%v) = %v, want %v: rank error %.4f", q, got, exactQuantile(data, q), e)
+		}
+		x := exactQuantile(data, q)
+		want := float64(sort.Search(len(sorted), func(i int) bool { return sorted[i] > x }))
+		if r := s.Rank(x); math.Abs(r-want) > eps*float64(len(data)) {
+			t.Errorf("Rank(%v) = %v, want %v", x, r, want)
+		}
+		if c := s.CDF(x); math.Abs(c-want/float64(len(data))) > eps {
+			t.Errorf("CDF(%v) = %v, want %v", x, c, want/float64(len(data)))
+		}
+	}
+	if s.Quantile(0) != sorted[0] || s.Quantile(1) != sorted[len(sorted)-1] {
+		t.Errorf("Quantile(0), Quantile(1) = %v, %v, want %v, %v", s.Quantile(0), s.Quantile(1), sorted[0], sorted[len(sorted)-1])
+	}
+}
+
+func TestKLLEncoding(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	s := NewKLL(64)
+	for _, x := range genStream(rng, 10000, "lognormal") {
+		s.Add(x)
+	}
+
+	b, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromBinary KLL
+	if err := fromBinary.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON KLL
+	if err := json.Unmarshal(j, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, got := range map[string]*KLL{"binary": &fromBinary, "JSON": &fromJSON} {
+		if got.Count() != s.Count() {
+			t.Errorf("%s: Count = %d, want %d", name, got.Count(), s.Count())
+		}
+		for _, q := range append(testQuantiles, 0, 1) {
+			if got.Quantile(q) != s.Quantile(q) {
+				t.Errorf("%s: Quantile(%v) = %v, want %v", name, q, got.Quantile(q), s.Quantile(q))
+			}
+			x := s.Quantile(q)
+			if got.Rank(x) != s.Rank(x) {
+				t.Errorf("%s: Rank(%v) = %v, want %v", name, x, got.Rank(x), s.Rank(x))
+			}
+		}
+	}
+
+	for i := range b {
+		var corrupt KLL
+		if err := corrupt.UnmarshalBinary(b[:i]); err == nil {
+			t.Fatalf("UnmarshalBinary of %d out of %d bytes succeeded", i, len(b))
+		}
+	}
+	for _, j := range []string{`{"k":3}`, `{"k":64,"n":2,"min":0,"max":1,"levels":[[0.5]]}`, `{"k":64,"n":1,"min":0,"max":1,"levels":[[2]]}`} {
+		var corrupt KLL
+		if err := corrupt.UnmarshalJSON([]byte(j)); err == nil {
+			t.Errorf("UnmarshalJSON(%s) succeeded", j)
+		}
+	}
+}
+
+func ExampleKLL() {
+	s := NewKLL(0)
+	for i := 1; i <= 1000; i++ {
+		s.Add(float64(i))
+	}
+	fmt.Println(s.Count(), s.Quantile(0), s.Quantile(1))
+	// Output: 1000 1 1000
+}
@@ -0,0 +1,96 @@
+// Package sketch provides mergeable summaries of streams of float64 values that answer
+// quantile and rank queries in memory that grows at most logarithmically with the
+// length of the stream, for when the values can't all be kept around to select from.
+//
+// KLL bounds the rank error of its answers, uniformly across quantiles, and is the
+// better choice when that guarantee matters. TDigest has no such bound, but is much
+// more accurate near the extreme quantiles, such as the 99.9th percentile of latencies.
+//
+// Both can be merged with sketches of the same kind built elsewhere, e.g. on other
+// hosts, and serialized in a compact binary format as well as in JSON to ship them
+// there. Neither is safe for concurrent use.
+package sketch
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// ErrCorrupt is returned when decoding a sketch from malformed data.
+var ErrCorrupt = errors.New("sketch: corrupt encoding")
+
+// formatVersion is the version of the binary formats, which follows their magic byte.
+const formatVersion = 1
+
+// appendFloat appends the little-endian encoding of x to b.
+func appendFloat(b []byte, x float64) []byte {
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(x))
+}
+
+// decoder reads the binary formats of sketches, recording the first error it runs into,
+// after which it only returns zero values.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) u8() byte {
+	if d.err != nil || len(d.b) < 1 {
+		d.err = ErrCorrupt
+		return 0
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = ErrCorrupt
+		return 0
+	}
+	d.b = d.b[n:]
+	return x
+}
+
+// length reads a count of items of size bytes each, checking that they fit in what's
+// left of the input, so that corrupt counts can't cause huge allocations.
+func (d *decoder) length(size int) int {
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(d.b)/size) {
+		d.err = ErrCorrupt
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) f64() float64 {
+	if d.err != nil || len(d.b) < 8 {
+		d.err = ErrCorrupt
+		return 0
+	}
+	x := math.Float64frombits(binary.LittleEndian.Uint64(d.b))
+	d.b = d.b[8:]
+	return x
+}
+
+// header checks the magic byte and version that start the binary format of a sketch.
+func (d *decoder) header(magic byte) {
+	if d.u8() != magic || d.u8() != formatVersion {
+		d.err = ErrCorrupt
+	}
+}
+
+// done returns the first error the decoder ran into, if any, or ErrCorrupt if there's
+// input left.
+func (d *decoder) done() error {
+	if d.err == nil && len(d.b) > 0 {
+		return ErrCorrupt
+	}
+	return d.err
+}
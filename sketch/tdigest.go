@@ -0,0 +1,313 @@
+package sketch
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+
+	"github.com/tsenart/kth"
+)
+
+// DefaultCompression is the compression NewTDigest uses when given one that isn't positive.
+const DefaultCompression = 100
+
+// TDigest is Dunning's t-digest, from "Computing Extremely Accurate Quantiles Using
+// t-Digests", 2019, in its merging variant.
+//
+// It summarizes values as centroids, each a mean and the number of values it stands
+// for, kept in order. New values are buffered, and once the buffer fills up they're
+// sorted along with the centroids, and adjacent centroids are merged as long as the
+// arcsine of the quantiles they span allows. That keeps centroids near the extremes
+// small, down to single values, so the tails are summarized more accurately than the
+// middle. Quantiles and ranks are interpolated between the centroids.
+//
+// With compression δ, a TDigest keeps at most about δ centroids, plus a buffer of 5δ
+// values. Its errors have no bound, unlike KLL's, but they are typically well below a
+// thousandth near the 0.1th and 99.9th percentiles with the default of 100.
+//
+// The zero value is an empty TDigest with the default compression.
+type TDigest struct {
+	compression float64
+	n           uint64
+	min, max    float64
+	centroids   []centroid // sorted by mean
+	buf         []centroid // values and centroids yet to be merged into centroids
+}
+
+type centroid struct {
+	mean, weight float64
+}
+
+// NewTDigest returns an empty TDigest with the given compression, or DefaultCompression
+// if compression isn't positive. It panics if compression isn't within [10, 100000].
+func NewTDigest(compression float64) *TDigest {
+	if !(compression > 0) {
+		compression = DefaultCompression
+	}
+	if !(compression >= 10 && compression <= 1e5) {
+		panic("sketch: t-digest compression out of range")
+	}
+	return &TDigest{compression: compression}
+}
+
+// init gives the zero value its default compression.
+func (s *TDigest) init() {
+	if s.compression == 0 {
+		s.compression = DefaultCompression
+	}
+}
+
+// Count returns the number of values added to s, including through Merge.
+func (s *TDigest) Count() uint64 {
+	return s.n
+}
+
+// Add adds x to the stream s summarizes. NaNs are ignored.
+func (s *TDigest) Add(x float64) {
+	if x != x {
+		return
+	}
+	s.init()
+	if s.n == 0 {
+		s.min, s.max = x, x
+	} else {
+		s.min, s.max = min(s.min, x), max(s.max, x)
+	}
+	s.n++
+
+	s.buf = append(s.buf, centroid{x, 1})
+	if len(s.buf) >= int(5*s.compression) {
+		s.compress()
+	}
+}
+
+// Merge adds all values summarized by o to s, as if they had been added to s directly,
+// leaving o untouched. The digests may have different compressions, s keeping its own.
+func (s *TDigest) Merge(o *TDigest) error {
+	s.init()
+	if o.n == 0 {
+		return nil
+	}
+	if s.n == 0 {
+		s.min, s.max = o.min, o.max
+	} else {
+		s.min, s.max = min(s.min, o.min), max(s.max, o.max)
+	}
+	s.n += o.n
+
+	s.buf = append(s.buf, o.centroids...)
+	s.buf = append(s.buf, o.buf...)
+	s.compress()
+	return nil
+}
+
+// scale maps the quantile q to the k1 scale of the t-digest paper, over which each
+// centroid may span at most 1.
+func (s *TDigest) scale(q float64) float64 {
+	return s.compression / (2 * math.Pi) * math.Asin(2*min(max(q, 0), 1)-1)
+}
+
+// compress merges the buffer into the centroids.
+func (s *TDigest) compress() {
+	if len(s.buf) == 0 {
+		return
+	}
+	all := append(s.centroids, s.buf...)
+	s.buf = s.buf[:0]
+	// Merging neighbours takes all the centroids in order, so kth's fused partial sort
+	// runs over all of them.
+	kth.PartialSortFunc(all, len(all), func(a, b centroid) bool { return a.mean < b.mean })
+
+	// Merge adjacent centroids in place, as long as the merged one spans at most 1 on
+	// the k1 scale.
+	total := float64(s.n)
+	cur, below := all[0], 0.0
+	limit := s.scale(0) + 1
+	w := 0
+	for _, c := range all[1:] {
+		if s.scale((below+cur.weight+c.weight)/total) <= limit {
+			cur.weight += c.weight
+			cur.mean += (c.mean - cur.mean) * c.weight / cur.weight
+			continue
+		}
+		all[w] = cur
+		w++
+		below += cur.weight
+		limit = s.scale(below/total) + 1
+		cur = c
+	}
+	all[w] = cur
+	s.centroids = all[:w+1]
+}
+
+// Quantile returns an estimate of the q-th quantile of the values added to s, for q
+// in [0, 1]. It returns the smallest and largest values added for 0 and 1, and NaN if
+// s is empty or q is out of range.
+func (s *TDigest) Quantile(q float64) float64 {
+	if s.n == 0 || !(q >= 0 && q <= 1) {
+		return math.NaN()
+	}
+	switch q {
+	case 0:
+		return s.min
+	case 1:
+		return s.max
+	}
+	s.compress()
+
+	// Each centroid's mean is taken to sit halfway through the values it stands for,
+	// with the smallest and largest values at either end.
+	t := q * float64(s.n)
+	cs := s.centroids
+	center := cs[0].weight / 2
+	if t < center {
+		return clamp(interpolate(s.min, cs[0].mean, t/center), s.min, s.max)
+	}
+	for i := 0; i < len(cs)-1; i++ {
+		next := center + (cs[i].weight+cs[i+1].weight)/2
+		if t < next {
+			return clamp(interpolate(cs[i].mean, cs[i+1].mean, (t-center)/(next-center)), s.min, s.max)
+		}
+		center = next
+	}
+	last := cs[len(cs)-1]
+	return clamp(interpolate(last.mean, s.max, (t-center)/(last.weight/2)), s.min, s.max)
+}
+
+// Rank returns an estimate of the number of values added to s that are less than or
+// equal to x.
+func (s *TDigest) Rank(x float64) float64 {
+	switch {
+	case s.n == 0 || x < s.min:
+		return 0
+	case x >= s.max:
+		return float64(s.n)
+	}
+	s.compress()
+
+	cs := s.centroids
+	center := cs[0].weight / 2
+	if x < cs[0].mean {
+		return interpolate(0, center, (x-s.min)/(cs[0].mean-s.min))
+	}
+	for i := 0; i < len(cs)-1; i++ {
+		next := center + (cs[i].weight+cs[i+1].weight)/2
+		if x < cs[i+1].mean {
+			return interpolate(center, next, (x-cs[i].mean)/(cs[i+1].mean-cs[i].mean))
+		}
+		center = next
+	}
+	last := cs[len(cs)-1]
+	return interpolate(center, float64(s.n), (x-last.mean)/(s.max-last.mean))
+}
+
+// CDF returns an estimate of the fraction of the values added to s that are less than
+// or equal to x. It returns NaN if s is empty.
+func (s *TDigest) CDF(x float64) float64 {
+	if s.n == 0 {
+		return math.NaN()
+	}
+	return s.Rank(x) / float64(s.n)
+}
+
+// interpolate returns the point a fraction h of the way from x to y.
+func interpolate(x, y, h float64) float64 {
+	return x + h*(y-x)
+}
+
+func clamp(x, lo, hi float64) float64 {
+	return min(max(x, lo), hi)
+}
+
+// tdigestMagic starts the binary format of a TDigest.
+const tdigestMagic = 'T'
+
+// MarshalBinary encodes s in a compact binary format.
+func (s *TDigest) MarshalBinary() ([]byte, error) {
+	s.init()
+	s.compress()
+	b := []byte{tdigestMagic, formatVersion}
+	b = appendFloat(b, s.compression)
+	b = binary.AppendUvarint(b, s.n)
+	b = appendFloat(b, s.min)
+	b = appendFloat(b, s.max)
+	b = binary.AppendUvarint(b, uint64(len(s.centroids)))
+	for _, c := range s.centroids {
+		b = appendFloat(b, c.mean)
+		b = appendFloat(b, c.weight)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a TDigest encoded by MarshalBinary into s, replacing its
+// contents.
+func (s *TDigest) UnmarshalBinary(b []byte) error {
+	d := decoder{b: b}
+	d.header(tdigestMagic)
+	var v tdigestJSON
+	v.Compression = d.f64()
+	v.N = d.uvarint()
+	v.Min, v.Max = d.f64(), d.f64()
+	v.Centroids = make([][2]float64, d.length(16))
+	for i := range v.Centroids {
+		v.Centroids[i] = [2]float64{d.f64(), d.f64()}
+	}
+	if err := d.done(); err != nil {
+		return err
+	}
+	return s.set(v)
+}
+
+// tdigestJSON is the JSON representation of a TDigest, with centroids as pairs of
+// mean and weight.
+type tdigestJSON struct {
+	Compression float64      `json:"compression"`
+	N           uint64       `json:"n"`
+	Min         float64      `json:"min"`
+	Max         float64      `json:"max"`
+	Centroids   [][2]float64 `json:"centroids"`
+}
+
+// MarshalJSON encodes s as a JSON object. It fails if s holds infinities, which JSON
+// can't represent.
+func (s *TDigest) MarshalJSON() ([]byte, error) {
+	s.init()
+	s.compress()
+	v := tdigestJSON{Compression: s.compression, N: s.n, Min: s.min, Max: s.max}
+	v.Centroids = make([][2]float64, len(s.centroids))
+	for i, c := range s.centroids {
+		v.Centroids[i] = [2]float64{c.mean, c.weight}
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a TDigest encoded by MarshalJSON into s, replacing its contents.
+func (s *TDigest) UnmarshalJSON(b []byte) error {
+	var v tdigestJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return s.set(v)
+}
+
+// set replaces the contents of s with v, checking that they are consistent.
+func (s *TDigest) set(v tdigestJSON) error {
+	if !(v.Compression >= 10 && v.Compression <= 1e5) {
+		return ErrCorrupt
+	}
+	centroids := make([]centroid, len(v.Centroids))
+	total := 0.0
+	for i, c := range v.Centroids {
+		mean, weight := c[0], c[1]
+		if !(mean >= v.Min && mean <= v.Max) || !(weight > 0) || i > 0 && mean < centroids[i-1].mean {
+			return ErrCorrupt
+		}
+		centroids[i] = centroid{mean, weight}
+		total += weight
+	}
+	if total != float64(v.N) {
+		return ErrCorrupt
+	}
+	*s = TDigest{compression: v.Compression, n: v.N, min: v.Min, max: v.Max, centroids: centroids}
+	return nil
+}
@@ -0,0 +1,158 @@
+package sketch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestTDigest(t *testing.T) {
+	rng := rand.New(rand.NewPCG(5, 6))
+
+	for _, dist := range testDistributions {
+		data := genStream(rng, 200000, dist)
+		sorted := slices.Clone(data)
+		slices.Sort(sorted)
+
+		t.Run(dist, func(t *testing.T) {
+			s := NewTDigest(0)
+			for _, x := range data {
+				s.Add(x)
+			}
+			checkTDigest(t, s, data, sorted)
+
+			// Merging digests of parts of the stream, some with a different compression.
+			merged := NewTDigest(0)
+			for i := 0; i < len(data); i += 20000 {
+				p := NewTDigest(float64(50 + i%3*50))
+				for _, x := range data[i:min(i+20000, len(data))] {
+					p.Add(x)
+				}
+				if err := merged.Merge(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			checkTDigest(t, merged, data, sorted)
+		})
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		var s TDigest
+		if q, r, c := s.Quantile(0.5), s.Rank(1), s.CDF(1); !math.IsNaN(q) || r != 0 || !math.IsNaN(c) {
+			t.Fatalf("Quantile, Rank, CDF = %v, %v, %v, want NaN, 0, NaN", q, r, c)
+		}
+		s.Add(math.NaN())
+		if s.Count() != 0 {
+			t.Fatalf("Count = %d after adding NaN, want 0", s.Count())
+		}
+		s.Add(3)
+		if q := s.Quantile(0.5); q != 3 {
+			t.Fatalf("Quantile(0.5) = %v, want 3", q)
+		}
+	})
+}
+
+// checkTDigest checks the answers of s against the exact ones for data, more strictly
+// in the tails.
+func checkTDigest(t *testing.T, s *TDigest, data, sorted []float64) {
+	t.Helper()
+
+	if s.Count() != uint64(len(data)) {
+		t.Fatalf("Count = %d, want %d", s.Count(), len(data))
+	}
+	if len(s.centroids) > int(s.compression) {
+		t.Errorf("%d centroids, want at most %v", len(s.centroids), s.compression)
+	}
+	for _, q := range testQuantiles {
+		eps := 0.01
+		if q <= 0.01 || q >= 0.99 {
+			eps = 0.0015
+		}
+		got := s.Quantile(q)
+		if e := rankError(sorted, got, q); e > eps {
+			t.Errorf("Quantile(%v) = %v, want %v: rank error %.4f", q, got, exactQuantile(data, q), e)
+		}
+		x := exactQuantile(data, q)
+		lo := float64(sort.SearchFloat64s(sorted, x))
+		hi := float64(sort.Search(len(sorted), func(i int) bool { return sorted[i] > x }))
+		// With duplicates, any estimate within the ranks of x is right.
+		if r := s.Rank(x); r < lo-eps*float64(len(data)) || r > hi+eps*float64(len(data)) {
+			t.Errorf("Rank(%v) = %v, want within [%v, %v]", x, r, lo, hi)
+		}
+	}
+	if s.Quantile(0) != sorted[0] || s.Quantile(1) != sorted[len(sorted)-1] {
+		t.Errorf("Quantile(0), Quantile(1) = %v, %v, want %v, %v", s.Quantile(0), s.Quantile(1), sorted[0], sorted[len(sorted)-1])
+	}
+}
+
+func TestTDigestEncoding(t *testing.T) {
+	rng := rand.New(rand.NewPCG(7, 8))
+	s := NewTDigest(50)
+	for _, x := range genStream(rng, 10000, "exponential") {
+		s.Add(x)
+	}
+
+	b, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromBinary TDigest
+	if err := fromBinary.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON TDigest
+	if err := json.Unmarshal(j, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, got := range map[string]*TDigest{"binary": &fromBinary, "JSON": &fromJSON} {
+		if got.Count() != s.Count() {
+			t.Errorf("%s: Count = %d, want %d", name, got.Count(), s.Count())
+		}
+		for _, q := range append(testQuantiles, 0, 1) {
+			if got.Quantile(q) != s.Quantile(q) {
+				t.Errorf("%s: Quantile(%v) = %v, want %v", name, q, got.Quantile(q), s.Quantile(q))
+			}
+			x := s.Quantile(q)
+			if got.Rank(x) != s.Rank(x) {
+				t.Errorf("%s: Rank(%v) = %v, want %v", name, x, got.Rank(x), s.Rank(x))
+			}
+		}
+	}
+
+	for i := range b {
+		var corrupt TDigest
+		if err := corrupt.UnmarshalBinary(b[:i]); err == nil {
+			t.Fatalf("UnmarshalBinary of %d out of %d bytes succeeded", i, len(b))
+		}
+	}
+	for _, j := range []string{
+		`{"compression":1}`,
+		`{"compression":100,"n":2,"min":0,"max":1,"centroids":[[0.5,1]]}`,
+		`{"compression":100,"n":2,"min":0,"max":1,"centroids":[[0.5,1],[0.25,1]]}`,
+		`{"compression":100,"n":1,"min":0,"max":1,"centroids":[[2,1]]}`,
+	} {
+		var corrupt TDigest
+		if err := corrupt.UnmarshalJSON([]byte(j)); err == nil {
+			t.Errorf("UnmarshalJSON(%s) succeeded", j)
+		}
+	}
+}
+
+func ExampleTDigest() {
+	s := NewTDigest(0)
+	for i := 1; i <= 1000; i++ {
+		s.Add(float64(i))
+	}
+	fmt.Println(s.Count(), s.Quantile(0), s.Quantile(1), s.CDF(1000))
+	// Output: 1000 1 1000 1
+}
@@ -0,0 +1,94 @@
+package kth
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// StableSelect swaps elements in the data provided so that the first k elements are
+// exactly the first k elements of a stable sort of data: among elements equal to the
+// k-th smallest one, those that come first in data are chosen. Both the chosen elements
+// and the remaining ones keep their original relative order, which makes the result
+// fully deterministic. Sorting the first k elements afterwards with sort.Stable yields
+// the first k elements of sort.Stable(data).
+//
+// StableSelect runs in O(n) expected time, using O(n) extra memory for a permutation of
+// indices. It does nothing when k is not within [1, n), since data is already
+// stably selected for k == n.
+func StableSelect(data sort.Interface, k int) {
+	n := data.Len()
+	if k < 1 || k >= n {
+		return
+	}
+
+	perm := identity(n)
+	pdqselect(&argSorter{data, perm}, 0, n, k-1, bits.Len(uint(n)))
+	kth := perm[k-1]
+
+	// Ties with the k-th element are chosen by their position in data.
+	ties := k
+	for i := 0; i < n; i++ {
+		if data.Less(i, kth) {
+			ties--
+		}
+	}
+
+	s, u := 0, k
+	for i := 0; i < n; i++ {
+		switch {
+		case data.Less(i, kth):
+		case ties > 0 && !data.Less(kth, i):
+			ties--
+		default:
+			perm[u] = i
+			u++
+			continue
+		}
+		perm[s] = i
+		s++
+	}
+
+	ApplyPermutation(perm, data.Swap)
+}
+
+// StableSelectFunc is a generic version of StableSelect that allows the caller to provide
+// a custom comparison function to determine the order of elements.
+func StableSelectFunc[E any](data []E, k int, less func(a, b E) bool) {
+	n := len(data)
+	if k < 1 || k >= n {
+		return
+	}
+
+	perm := identity(n)
+	pdqselectFunc(perm, 0, n, k-1, bits.Len(uint(n)), func(i, j int) bool {
+		return less(data[i], data[j])
+	})
+	kth := data[perm[k-1]]
+
+	// Ties with the k-th element are chosen by their position in data.
+	ties := k
+	for i := range data {
+		if less(data[i], kth) {
+			ties--
+		}
+	}
+
+	s, u := 0, k
+	for i := range data {
+		switch {
+		case less(data[i], kth):
+		case ties > 0 && !less(kth, data[i]):
+			ties--
+		default:
+			perm[u] = i
+			u++
+			continue
+		}
+		perm[s] = i
+		s++
+	}
+
+	ApplyPermutation(perm, func(i, j int) {
+		data[i], data[j] = data[j], data[i]
+	})
+}
@@ -0,0 +1,75 @@
+package kth
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+)
+
+type record struct {
+	score int
+	id    int
+}
+
+type records []record
+
+func (r records) Len() int           { return len(r) }
+func (r records) Less(i, j int) bool { return r[i].score < r[j].score }
+func (r records) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+func TestStableSelect(t *testing.T) {
+	rng := rand.New(rand.NewPCG(13, 14))
+
+	funcs := []struct {
+		name string
+		fn   func([]record, int)
+	}{
+		{"StableSelect", func(data []record, k int) { StableSelect(records(data), k) }},
+		{"StableSelectFunc", func(data []record, k int) {
+			StableSelectFunc(data, k, func(a, b record) bool { return a.score < b.score })
+		}},
+	}
+
+	for _, size := range []int{1, 2, 13, 100, 2000} {
+		for _, distinct := range []int{1, 3, size} {
+			input := make([]record, size)
+			for i := range input {
+				input[i] = record{score: rng.IntN(distinct), id: i}
+			}
+
+			stable := slices.Clone(input)
+			slices.SortStableFunc(stable, func(a, b record) int { return cmp.Compare(a.score, b.score) })
+
+			for _, k := range []int{1, size / 3, size / 2, size - 1, size} {
+				if k < 1 {
+					continue
+				}
+				for _, f := range funcs {
+					name := fmt.Sprintf("%s/n=%d/distinct=%d/k=%d", f.name, size, distinct, k)
+					t.Run(name, func(t *testing.T) {
+						output := slices.Clone(input)
+						f.fn(output, k)
+
+						want := slices.Clone(stable[:k])
+						slices.SortFunc(want, func(a, b record) int { return cmp.Compare(a.id, b.id) })
+						if !slices.Equal(output[:k], want) {
+							t.Fatalf("prefix does not hold the first k elements of a stable sort in their original order\nwant: %v\ngot:  %v", want, output[:k])
+						}
+
+						if !slices.IsSortedFunc(output[k:], func(a, b record) int { return cmp.Compare(a.id, b.id) }) {
+							t.Fatalf("remaining elements lost their original order: %v", output[k:])
+						}
+
+						sort.Stable(records(output[:k]))
+						if !slices.Equal(output[:k], stable[:k]) {
+							t.Fatalf("stable sort of prefix does not match stable sort of data")
+						}
+					})
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package kth
+
+import (
+	"math/bits"
+	"slices"
+)
+
+// TopK accumulates the k smallest elements, according to less, of a stream of elements
+// that are pushed to it one at a time. It's meant for inputs that can't be materialized
+// in full before selecting from them. To keep the k largest elements instead, invert less.
+//
+// A TopK uses one of two strategies, depending on how it was constructed:
+//
+//   - NewTopK buffers up to 2k elements and uses pdqselect to compact them back down to
+//     the k smallest whenever the buffer fills up. Elements that aren't smaller than the
+//     k-th smallest element found by the last compaction are rejected with a single call
+//     to less. This takes O(1) amortized time per element.
+//   - NewHeapTopK maintains a max-heap of the k smallest elements seen so far, taking
+//     O(log k) time per element that enters the heap. It uses exactly k elements of memory.
+//
+// A TopK must not be used concurrently.
+type TopK[E any] struct {
+	k    int
+	less func(a, b E) bool
+	heap bool // whether to use the heap strategy rather than the buffered one
+	buf  []E
+
+	// With the buffered strategy, bounded is true once buf has been compacted at least
+	// once, from which point on buf[k-1] holds the k-th smallest element of the last
+	// compaction. selected is true when that's also the k-th smallest element of buf.
+	bounded  bool
+	selected bool
+}
+
+// NewTopK returns a TopK keeping the k smallest elements according to less,
+// using the buffered strategy. If k < 1, it doesn't keep any element.
+func NewTopK[E any](k int, less func(a, b E) bool) *TopK[E] {
+	k = max(k, 0)
+	return &TopK[E]{k: k, less: less, buf: make([]E, 0, 2*k)}
+}
+
+// NewHeapTopK returns a TopK keeping the k smallest elements according to less,
+// using the heap strategy. If k < 1, it doesn't keep any element.
+func NewHeapTopK[E any](k int, less func(a, b E) bool) *TopK[E] {
+	k = max(k, 0)
+	return &TopK[E]{k: k, less: less, heap: true, buf: make([]E, 0, k)}
+}
+
+// Push offers x to the accumulator, which keeps it if it's among the k smallest
+// elements pushed so far.
+func (t *TopK[E]) Push(x E) {
+	if t.k == 0 {
+		return
+	}
+
+	if t.heap {
+		t.pushHeap(x)
+		return
+	}
+
+	// Everything that isn't smaller than the k-th smallest element of the
+	// last compaction is bound to be dropped by the next one.
+	if t.bounded && !t.less(x, t.buf[t.k-1]) {
+		return
+	}
+
+	t.buf = append(t.buf, x)
+	t.selected = false
+	if len(t.buf) == 2*t.k {
+		t.compact()
+	}
+}
+
+func (t *TopK[E]) pushHeap(x E) {
+	if len(t.buf) < t.k {
+		t.buf = append(t.buf, x)
+		if len(t.buf) == t.k {
+			// Build a max-heap of the first k elements.
+			for i := (t.k - 1) / 2; i >= 0; i-- {
+				siftDownLessFunc(t.buf, i, t.k, 0, t.less)
+			}
+		}
+		return
+	}
+
+	if t.less(x, t.buf[0]) {
+		t.buf[0] = x
+		siftDownLessFunc(t.buf, 0, t.k, 0, t.less)
+	}
+}
+
+// compact reduces the buffer to its k smallest elements, with the k-th smallest at k-1.
+func (t *TopK[E]) compact() {
+	if t.k == 0 || t.selected || len(t.buf) < t.k {
+		return
+	}
+	pdqselectFunc(t.buf, 0, len(t.buf), t.k-1, bits.Len(uint(len(t.buf))), t.less)
+	clear(t.buf[t.k:]) // Let the garbage collector reclaim dropped elements.
+	t.buf = t.buf[:t.k]
+	t.bounded, t.selected = true, true
+}
+
+// Len returns the number of elements currently kept, which is the smaller of k
+// and the number of elements pushed so far.
+func (t *TopK[E]) Len() int {
+	return min(len(t.buf), t.k)
+}
+
+// Threshold returns the k-th smallest element pushed so far, which is the largest of
+// the elements kept and the one any new element has to be smaller than to be kept.
+// It returns false if fewer than k elements have been pushed.
+func (t *TopK[E]) Threshold() (E, bool) {
+	if t.k == 0 || len(t.buf) < t.k {
+		var zero E
+		return zero, false
+	}
+	if t.heap {
+		return t.buf[0], true
+	}
+	t.compact()
+	return t.buf[t.k-1], true
+}
+
+// Result returns a new slice holding the kept elements in no particular order.
+func (t *TopK[E]) Result() []E {
+	if !t.heap {
+		t.compact()
+	}
+	return slices.Clone(t.buf[:t.Len()])
+}
+
+// Sorted returns a new slice holding the kept elements, sorted according to less.
+func (t *TopK[E]) Sorted() []E {
+	res := t.Result()
+	pdqsortLessFunc(res, 0, len(res), bits.Len(uint(len(res))), t.less)
+	return res
+}
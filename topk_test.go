@@ -0,0 +1,143 @@
+package kth
+
+import (
+	"cmp"
+	"container/heap"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestTopK(t *testing.T) {
+	rng := rand.New(rand.NewPCG(17, 18))
+
+	constructors := []struct {
+		name string
+		new  func(k int, less func(a, b int) bool) *TopK[int]
+	}{
+		{"Buffered", NewTopK[int]},
+		{"Heap", NewHeapTopK[int]},
+	}
+
+	for _, c := range constructors {
+		for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist} {
+			for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder} {
+				for _, size := range []int{0, 1, 10, 1000} {
+					input := genDistribution(rng, max(size, 1), dist)[:size]
+					applyOrdering(rng, input, order)
+					sorted := slices.Clone(input)
+					slices.Sort(sorted)
+
+					for _, k := range []int{0, 1, 7, 100, 2000} {
+						name := fmt.Sprintf("%s/n=%d/k=%d/dist=%s/order=%s", c.name, size, k, dist, order)
+						t.Run(name, func(t *testing.T) {
+							topk := c.new(k, cmp.Less)
+							want := sorted[:min(k, size)]
+
+							for i, x := range input {
+								topk.Push(x)
+
+								if i%97 != 0 {
+									continue
+								}
+
+								// Check the threshold along the way, which also
+								// exercises compacting at arbitrary points.
+								seen := slices.Clone(input[:i+1])
+								slices.Sort(seen)
+								thr, ok := topk.Threshold()
+								if wantOK := k > 0 && len(seen) >= k; ok != wantOK {
+									t.Fatalf("after %d pushes: Threshold() ok = %v, want %v", i+1, ok, wantOK)
+								} else if ok && thr != seen[k-1] {
+									t.Fatalf("after %d pushes: Threshold() = %d, want %d", i+1, thr, seen[k-1])
+								}
+							}
+
+							if got := topk.Len(); got != len(want) {
+								t.Errorf("Len() = %d, want %d", got, len(want))
+							}
+
+							result := topk.Result()
+							slices.Sort(result)
+							if !slices.Equal(result, want) {
+								t.Errorf("Result() = %v, want %v", result, want)
+							}
+
+							if sorted := topk.Sorted(); !slices.Equal(sorted, want) {
+								t.Errorf("Sorted() = %v, want %v", sorted, want)
+							}
+						})
+					}
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkTopK(b *testing.B) {
+	rng := rand.New(rand.NewPCG(42, 42))
+
+	const n = 1_000_000
+	for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder} {
+		data := genDistribution(rng, n, UniformDist)
+		applyOrdering(rng, data, order)
+
+		for _, k := range []int{10, 1000, 100_000} {
+			cases := []struct {
+				name string
+				fn   func([]int, int) []int
+			}{
+				{"Buffered", func(data []int, k int) []int {
+					topk := NewTopK(k, cmp.Less[int])
+					for _, x := range data {
+						topk.Push(x)
+					}
+					return topk.Result()
+				}},
+				{"Heap", func(data []int, k int) []int {
+					topk := NewHeapTopK(k, cmp.Less[int])
+					for _, x := range data {
+						topk.Push(x)
+					}
+					return topk.Result()
+				}},
+				{"ContainerHeap", func(data []int, k int) []int {
+					h := make(maxHeap, 0, k)
+					for _, x := range data {
+						if len(h) < k {
+							heap.Push(&h, x)
+						} else if x < h[0] {
+							h[0] = x
+							heap.Fix(&h, 0)
+						}
+					}
+					return h
+				}},
+			}
+
+			for _, bc := range cases {
+				name := fmt.Sprintf("fn=%s/n=%d/k=%d/order=%s", bc.name, n, k, order)
+				b.Run(name, func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						bc.fn(data, k)
+					}
+				})
+			}
+		}
+	}
+}
+
+type maxHeap []int
+
+func (h maxHeap) Len() int           { return len(h) }
+func (h maxHeap) Less(i, j int) bool { return h[i] > h[j] }
+func (h maxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x any)        { *h = append(*h, x.(int)) }
+func (h *maxHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,232 @@
+package kth
+
+import (
+	"cmp"
+	"math"
+	"math/bits"
+)
+
+// WeightedSelect swaps elements in the data provided, moving the weights in the parallel
+// slice weights along with them, so that data[i] is the element at which the cumulative
+// weight of the elements, taken in ascending order, reaches target, and returns i. The
+// elements in data[:i] are no greater than data[i] and their weights add up to less than
+// target, while adding weights[i] reaches it. The elements in data[i+1:] are no smaller.
+//
+// For instance, with target set to half the total weight, data[i] is the weighted
+// median. With the order reversed through WeightedSelectFunc and probabilities as
+// weights, data[:i+1] is the smallest set of most likely outcomes whose probability
+// reaches target, as top-p sampling needs.
+//
+// It's a quickselect that, rather than comparing the rank of the pivot with k, adds up
+// the weights of the elements smaller than the pivot to decide which side to carry on
+// with, partitioning as PDQSelectByKeys does. That takes O(n) expected time.
+//
+// Weights must not be negative. They are added up as float64s, so the result may be
+// off by one element when rounding makes their sum land on either side of target.
+// WeightedSelect panics if data and weights don't have the same length. It returns -1
+// without touching either slice if data is empty or target exceeds the total weight.
+func WeightedSelect[T cmp.Ordered, W Number](data []T, weights []W, target float64) int {
+	n := checkWeights(data, weights)
+	if n == 0 || !(target <= weightSum(weights, 0, n)) {
+		return -1
+	}
+	return weightedSelectOrdered(data, weights, target)
+}
+
+// WeightedSelectFunc is a generic version of WeightedSelect that allows the caller to
+// provide a custom comparison function to determine the order of elements.
+func WeightedSelectFunc[E any, W Number](data []E, weights []W, target float64, less func(a, b E) bool) int {
+	n := checkWeights(data, weights)
+	if n == 0 || !(target <= weightSum(weights, 0, n)) {
+		return -1
+	}
+	return weightedSelectLessFunc(data, weights, target, less)
+}
+
+// WeightedQuantile returns the q-th weighted quantile of data, for q in [0, 1], which
+// is the smallest element at which the cumulative weight reaches q times the total
+// weight, as with InvertedCDF when all weights are equal. It returns NaN if data is
+// empty, q is out of range, or the total weight isn't positive.
+//
+// WeightedQuantile reorders data and weights in place, as WeightedSelect does, and
+// panics if they don't have the same length.
+func WeightedQuantile[T, W Number](data []T, weights []W, q float64) float64 {
+	n := checkWeights(data, weights)
+	if n == 0 || !(q >= 0 && q <= 1) {
+		return math.NaN()
+	}
+	total := weightSum(weights, 0, n)
+	if !(total > 0) {
+		return math.NaN()
+	}
+	return float64(data[weightedSelectOrdered(data, weights, min(q*total, total))])
+}
+
+// WeightedMedian returns the weighted median of data, which is its lower weighted median
+// when an element splits the total weight in two equal halves. It returns NaN if data
+// is empty or the total weight isn't positive. WeightedMedian reorders data and weights
+// in place.
+func WeightedMedian[T, W Number](data []T, weights []W) float64 {
+	return WeightedQuantile(data, weights, 0.5)
+}
+
+// checkWeights returns the common length of data and weights, panicking if they differ.
+func checkWeights[E any, W Number](data []E, weights []W) int {
+	if len(data) != len(weights) {
+		panic("kth: data and weights have different lengths")
+	}
+	return len(data)
+}
+
+// weightSum returns the sum of weights[a:b].
+func weightSum[W Number](weights []W, a, b int) float64 {
+	sum := 0.0
+	for _, w := range weights[a:b] {
+		sum += float64(w)
+	}
+	return sum
+}
+
+// weightScan returns the index within [a, b), over which the elements are sorted, of the
+// element at which the cumulative weight, starting from acc, reaches target.
+func weightScan[W Number](weights []W, a, b int, acc, target float64) int {
+	for i := a; i < b; i++ {
+		if acc += float64(weights[i]); acc >= target {
+			return i
+		}
+	}
+	return b - 1 // Rounding kept the sum short of target.
+}
+
+// weightedSelectOrdered finds the element of data at which the cumulative weight reaches
+// target, which must not exceed the total weight, mirroring pdqselectByKeys.
+func weightedSelectOrdered[T cmp.Ordered, W Number](data []T, weights []W, target float64) int {
+	const maxInsertion = 12
+
+	a, b := 0, len(data)
+	acc := 0.0 // The weight of data[:a].
+	limit := bits.Len(uint(b))
+	wasBalanced := true
+
+	for {
+		length := b - a
+
+		if length <= maxInsertion {
+			insertionSortByKeys(data, weights, a, b, cmp.Less[T])
+			return weightScan(weights, a, b, acc, target)
+		}
+
+		// Fall back to heapsort if too many bad choices were made.
+		if limit == 0 {
+			heapSortByKeys(data, weights, a, b, cmp.Less[T])
+			return weightScan(weights, a, b, acc, target)
+		}
+
+		// Break patterns if the last partitioning was imbalanced
+		if !wasBalanced {
+			breakPatternsByKeys(data, weights, a, b)
+			limit--
+		}
+
+		pivot, _ := choosePivotOrdered(data, a, b)
+
+		// Probably the slice contains many duplicate elements. Elements equal to each other
+		// are as good as sorted, so if their weight reaches target, one of them is it.
+		if a > 0 && data[a-1] >= data[pivot] {
+			mid := partitionEqualByKeys(data, weights, a, b, pivot, cmp.Less[T])
+			w := weightSum(weights, a, mid)
+			if acc+w >= target {
+				return weightScan(weights, a, mid, acc, target)
+			}
+			acc += w
+			a = mid
+			continue
+		}
+
+		var mid int
+		if useBlockPartition[T](length) {
+			mid, _ = partitionBlockByKeys(data, weights, a, b, pivot)
+		} else {
+			mid, _ = partitionByKeys(data, weights, a, b, pivot, cmp.Less[T])
+		}
+		leftLen, rightLen := mid-a, b-mid
+		balanceThreshold := length / 8
+
+		w := weightSum(weights, a, mid)
+		if mid > a && acc+w >= target {
+			wasBalanced = leftLen >= balanceThreshold
+			b = mid
+			continue
+		}
+		acc += w
+		if acc += float64(weights[mid]); acc >= target {
+			return mid
+		}
+		wasBalanced = rightLen >= balanceThreshold
+		a = mid + 1
+	}
+}
+
+// weightedSelectLessFunc mirrors weightedSelectOrdered, comparing the elements with less.
+func weightedSelectLessFunc[E any, W Number](data []E, weights []W, target float64, less func(a, b E) bool) int {
+	const maxInsertion = 12
+
+	a, b := 0, len(data)
+	acc := 0.0 // The weight of data[:a].
+	limit := bits.Len(uint(b))
+	wasBalanced := true
+
+	for {
+		length := b - a
+
+		if length <= maxInsertion {
+			insertionSortByKeys(data, weights, a, b, less)
+			return weightScan(weights, a, b, acc, target)
+		}
+
+		// Fall back to heapsort if too many bad choices were made.
+		if limit == 0 {
+			heapSortByKeys(data, weights, a, b, less)
+			return weightScan(weights, a, b, acc, target)
+		}
+
+		// Break patterns if the last partitioning was imbalanced
+		if !wasBalanced {
+			breakPatternsByKeys(data, weights, a, b)
+			limit--
+		}
+
+		// choosePivotLessFunc only reads data, so it can be shared.
+		pivot, _ := choosePivotLessFunc(data, a, b, less)
+
+		// Probably the slice contains many duplicate elements. Elements equal to each other
+		// are as good as sorted, so if their weight reaches target, one of them is it.
+		if a > 0 && !less(data[a-1], data[pivot]) {
+			mid := partitionEqualByKeys(data, weights, a, b, pivot, less)
+			w := weightSum(weights, a, mid)
+			if acc+w >= target {
+				return weightScan(weights, a, mid, acc, target)
+			}
+			acc += w
+			a = mid
+			continue
+		}
+
+		mid, _ := partitionByKeys(data, weights, a, b, pivot, less)
+		leftLen, rightLen := mid-a, b-mid
+		balanceThreshold := length / 8
+
+		w := weightSum(weights, a, mid)
+		if mid > a && acc+w >= target {
+			wasBalanced = leftLen >= balanceThreshold
+			b = mid
+			continue
+		}
+		acc += w
+		if acc += float64(weights[mid]); acc >= target {
+			return mid
+		}
+		wasBalanced = rightLen >= balanceThreshold
+		a = mid + 1
+	}
+}
@@ -0,0 +1,178 @@
+package kth
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestWeightedSelect(t *testing.T) {
+	rng := rand.New(rand.NewPCG(43, 44))
+
+	for _, dist := range []Distribution{UniformDist, ZipfDist, ConstantDist, BimodalDist} {
+		for _, order := range []Ordering{RandomOrder, SortedOrder, ReversedOrder, PushFrontOrder} {
+			for _, size := range []int{1, 13, 300, 5000} {
+				input := genDistribution(rng, size, dist)
+				applyOrdering(rng, input, order)
+				weights := make([]int, size)
+				for i := range weights {
+					weights[i] = rng.IntN(10)
+				}
+				total := 0
+				for _, w := range weights {
+					total += w
+				}
+
+				for _, target := range []int{0, 1, total / 10, total / 2, total - 1, total} {
+					target = min(target, total)
+					name := fmt.Sprintf("n=%d/target=%d/dist=%s/order=%s", size, target, dist, order)
+					t.Run(name, func(t *testing.T) {
+						testWeightedSelect(t, input, weights, target)
+					})
+				}
+			}
+		}
+	}
+
+	t.Run("Quantiles", func(t *testing.T) {
+		data := []float64{5, 1, 4, 2, 3}
+		weights := []float64{1, 1, 1, 1, 6}
+		for _, tc := range []struct{ q, want float64 }{
+			{0, 1}, {0.1, 1}, {0.2, 2}, {0.25, 3}, {0.5, 3}, {0.8, 3}, {0.81, 4}, {1, 5},
+		} {
+			if got := WeightedQuantile(slices.Clone(data), slices.Clone(weights), tc.q); got != tc.want {
+				t.Errorf("WeightedQuantile(q=%v) = %v, want %v", tc.q, got, tc.want)
+			}
+		}
+		if got := WeightedMedian([]int{1, 2, 3, 4}, []int{1, 1, 1, 1}); got != 2 {
+			t.Errorf("WeightedMedian = %v, want 2", got)
+		}
+		for _, q := range []float64{-0.1, 1.1, math.NaN()} {
+			if got := WeightedQuantile(slices.Clone(data), slices.Clone(weights), q); !math.IsNaN(got) {
+				t.Errorf("WeightedQuantile(q=%v) = %v, want NaN", q, got)
+			}
+		}
+		if got := WeightedMedian([]int{1, 2}, []int{0, 0}); !math.IsNaN(got) {
+			t.Errorf("WeightedMedian with no weight = %v, want NaN", got)
+		}
+	})
+
+	t.Run("Top-p", func(t *testing.T) {
+		probs := []float64{0.05, 0.4, 0.1, 0.3, 0.15}
+		i := WeightedSelectFunc(probs, slices.Clone(probs), 0.8, func(a, b float64) bool { return a > b })
+		got := slices.Clone(probs[:i+1])
+		slices.Sort(got)
+		if want := []float64{0.15, 0.3, 0.4}; !slices.Equal(got, want) {
+			t.Fatalf("WeightedSelectFunc selected %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Out of range", func(t *testing.T) {
+		data, weights := []int{3, 1, 2}, []int{1, 1, 1}
+		for _, target := range []float64{3.5, math.NaN()} {
+			if i := WeightedSelect(data, weights, target); i != -1 || !slices.Equal(data, []int{3, 1, 2}) {
+				t.Fatalf("target=%v: WeightedSelect = %d, data = %v", target, i, data)
+			}
+		}
+		if i := WeightedSelect([]int(nil), []int(nil), 0); i != -1 {
+			t.Fatalf("WeightedSelect(nil) = %d, want -1", i)
+		}
+	})
+
+	t.Run("Mismatched lengths", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("WeightedSelect didn't panic")
+			}
+		}()
+		WeightedSelect([]int{1, 2}, []int{1}, 1)
+	})
+}
+
+func FuzzWeightedSelect(f *testing.F) {
+	f.Add(encodeInts(1, 4, 2, 1, 4, 4), uint8(3), uint16(7))
+	f.Add(encodeInts(5, 5, 5, 5, 5), uint8(0), uint16(0))
+
+	f.Fuzz(func(t *testing.T, data []byte, seed uint8, target uint16) {
+		input := decodeInts(data)
+		if len(input) == 0 {
+			return
+		}
+		rng := rand.New(rand.NewPCG(uint64(seed), 0))
+		weights := make([]int, len(input))
+		total := 0
+		for i := range weights {
+			weights[i] = rng.IntN(4)
+			total += weights[i]
+		}
+		testWeightedSelect(t, input, weights, int(target)%(total+1))
+	})
+}
+
+// testWeightedSelect checks WeightedSelect and WeightedSelectFunc against scanning the
+// pairs of input and weights sorted by element, for integer weights whose sums are exact.
+func testWeightedSelect(t *testing.T, input, weights []int, target int) {
+	t.Helper()
+
+	for _, name := range []string{"WeightedSelect", "WeightedSelectFunc"} {
+		data, ws := slices.Clone(input), slices.Clone(weights)
+		var i int
+		if name == "WeightedSelect" {
+			i = WeightedSelect(data, ws, float64(target))
+		} else {
+			i = WeightedSelectFunc(data, ws, float64(target), func(a, b int) bool { return a < b })
+		}
+		if i < 0 {
+			t.Fatalf("%s: returned %d", name, i)
+		}
+
+		before := 0
+		for j, x := range data {
+			if j < i && x > data[i] || j > i && x < data[i] {
+				t.Fatalf("%s: element %d at index %d is on the wrong side of %d at %d", name, x, j, data[i], i)
+			}
+			if j < i {
+				before += ws[j]
+			}
+		}
+		if (before >= target || before+ws[i] < target) && !(target <= 0 && i == 0) {
+			t.Fatalf("%s: cumulative weight %d before %d and %d after it, want it to cross %d", name, before, data[i], before+ws[i], target)
+		}
+
+		// The lowest element at which the cumulative weight reaches target is unique.
+		type pair struct{ x, w int }
+		sorted := make([]pair, len(input))
+		for j := range input {
+			sorted[j] = pair{input[j], weights[j]}
+		}
+		slices.SortFunc(sorted, func(a, b pair) int { return a.x - b.x })
+		acc, want := 0, sorted[len(sorted)-1].x
+		for _, p := range sorted {
+			if acc += p.w; acc >= target {
+				want = p.x
+				break
+			}
+		}
+		if data[i] != want {
+			t.Fatalf("%s: selected %d, want %d", name, data[i], want)
+		}
+
+		got := make([]pair, len(data))
+		for j := range data {
+			got[j] = pair{data[j], ws[j]}
+		}
+		cmpPairs := func(a, b pair) int {
+			if a.x != b.x {
+				return a.x - b.x
+			}
+			return a.w - b.w
+		}
+		slices.SortFunc(got, cmpPairs)
+		slices.SortFunc(sorted, cmpPairs)
+		if !slices.Equal(got, sorted) {
+			t.Fatalf("%s: weights didn't move along with their elements", name)
+		}
+	}
+}